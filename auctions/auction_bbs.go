@@ -0,0 +1,82 @@
+package auctions
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+)
+
+// An AuctionBBS finds the start and stop auctions of a single process,
+// without reading those of every other process along with them.
+type AuctionBBS interface {
+	GetLRPStartAuctionsByProcessGuid(processGuid string) ([]models.LRPStartAuction, error)
+	GetLRPStopAuctionsByProcessGuid(processGuid string) ([]models.LRPStopAuction, error)
+}
+
+type auctionBBS struct {
+	store storeadapter.StoreAdapter
+}
+
+func NewAuctionBBS(store storeadapter.StoreAdapter) AuctionBBS {
+	return &auctionBBS{
+		store: store,
+	}
+}
+
+func (bbs *auctionBBS) GetLRPStartAuctionsByProcessGuid(processGuid string) ([]models.LRPStartAuction, error) {
+	startAuctions := []models.LRPStartAuction{}
+
+	nodes, err := bbs.list(path.Join(shared.LRPStartAuctionSchemaRoot, processGuid))
+	if err != nil {
+		return startAuctions, err
+	}
+
+	for _, node := range nodes {
+		startAuction, err := models.NewLRPStartAuctionFromJSON(node.Value)
+		if err != nil {
+			return startAuctions, fmt.Errorf("cannot parse lrp JSON for key %s: %s", node.Key, err.Error())
+		}
+
+		startAuctions = append(startAuctions, startAuction)
+	}
+
+	return startAuctions, nil
+}
+
+func (bbs *auctionBBS) GetLRPStopAuctionsByProcessGuid(processGuid string) ([]models.LRPStopAuction, error) {
+	stopAuctions := []models.LRPStopAuction{}
+
+	nodes, err := bbs.list(path.Join(shared.LRPStopAuctionSchemaRoot, processGuid))
+	if err != nil {
+		return stopAuctions, err
+	}
+
+	for _, node := range nodes {
+		stopAuction, err := models.NewLRPStopAuctionFromJSON(node.Value)
+		if err != nil {
+			return stopAuctions, fmt.Errorf("cannot parse lrp JSON for key %s: %s", node.Key, err.Error())
+		}
+
+		stopAuctions = append(stopAuctions, stopAuction)
+	}
+
+	return stopAuctions, nil
+}
+
+// list returns the auctions under the directory of a process, one per index.
+// A process without any auctions has no directory.
+func (bbs *auctionBBS) list(key string) ([]storeadapter.StoreNode, error) {
+	node, err := bbs.store.ListRecursively(key)
+	if err == storeadapter.ErrorKeyNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return node.ChildNodes, nil
+}
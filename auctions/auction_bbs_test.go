@@ -0,0 +1,112 @@
+package auctions_test
+
+import (
+	"errors"
+	"regexp"
+
+	. "github.com/cloudfoundry-incubator/app-manager/auctions"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuctionBBS", func() {
+	var (
+		store *fakestoreadapter.FakeStoreAdapter
+		bbs   AuctionBBS
+	)
+
+	BeforeEach(func() {
+		store = fakestoreadapter.New()
+		bbs = NewAuctionBBS(store)
+	})
+
+	Describe("GetLRPStartAuctionsByProcessGuid", func() {
+		startAuction := func(processGuid string, index int) models.LRPStartAuction {
+			return models.LRPStartAuction{
+				DesiredLRP:   models.DesiredLRP{ProcessGuid: processGuid},
+				Index:        index,
+				InstanceGuid: "some-instance-guid",
+			}
+		}
+
+		BeforeEach(func() {
+			err := store.SetMulti([]storeadapter.StoreNode{
+				{Key: "/v1/start/some-process-guid/0", Value: startAuction("some-process-guid", 0).ToJSON()},
+				{Key: "/v1/start/some-process-guid/1", Value: startAuction("some-process-guid", 1).ToJSON()},
+				{Key: "/v1/start/some-other-process-guid/0", Value: startAuction("some-other-process-guid", 0).ToJSON()},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns the start auctions of the process only", func() {
+			startAuctions, err := bbs.GetLRPStartAuctionsByProcessGuid("some-process-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(startAuctions).Should(ConsistOf(
+				startAuction("some-process-guid", 0),
+				startAuction("some-process-guid", 1),
+			))
+		})
+
+		It("only lists the directory of the process", func() {
+			store.ListErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector(`^/v1/start$`, errors.New("too much"))
+
+			_, err := bbs.GetLRPStartAuctionsByProcessGuid("some-process-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns nothing for a process without start auctions", func() {
+			startAuctions, err := bbs.GetLRPStartAuctionsByProcessGuid("unknown-process-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(startAuctions).Should(BeEmpty())
+		})
+
+		It("fails on an auction it cannot parse", func() {
+			err := store.SetMulti([]storeadapter.StoreNode{
+				{Key: "/v1/start/some-process-guid/2", Value: []byte("ß")},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = bbs.GetLRPStartAuctionsByProcessGuid("some-process-guid")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		Context("when listing fails", func() {
+			BeforeEach(func() {
+				store.ListErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector(regexp.QuoteMeta("/v1/start/some-process-guid"), errors.New("oh no"))
+			})
+
+			It("returns the error", func() {
+				_, err := bbs.GetLRPStartAuctionsByProcessGuid("some-process-guid")
+				Ω(err).Should(MatchError("oh no"))
+			})
+		})
+	})
+
+	Describe("GetLRPStopAuctionsByProcessGuid", func() {
+		BeforeEach(func() {
+			err := store.SetMulti([]storeadapter.StoreNode{
+				{Key: "/v1/stop/some-process-guid/0", Value: models.LRPStopAuction{ProcessGuid: "some-process-guid", Index: 0}.ToJSON()},
+				{Key: "/v1/stop/some-other-process-guid/1", Value: models.LRPStopAuction{ProcessGuid: "some-other-process-guid", Index: 1}.ToJSON()},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns the stop auctions of the process only", func() {
+			stopAuctions, err := bbs.GetLRPStopAuctionsByProcessGuid("some-process-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stopAuctions).Should(Equal([]models.LRPStopAuction{
+				{ProcessGuid: "some-process-guid", Index: 0},
+			}))
+		})
+
+		It("returns nothing for a process without stop auctions", func() {
+			stopAuctions, err := bbs.GetLRPStopAuctionsByProcessGuid("unknown-process-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stopAuctions).Should(BeEmpty())
+		})
+	})
+})
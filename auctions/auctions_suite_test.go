@@ -0,0 +1,13 @@
+package auctions_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAuctions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Auctions Suite")
+}
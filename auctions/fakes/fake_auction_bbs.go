@@ -0,0 +1,98 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/auctions"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeAuctionBBS struct {
+	GetLRPStartAuctionsByProcessGuidStub        func(processGuid string) ([]models.LRPStartAuction, error)
+	getLRPStartAuctionsByProcessGuidMutex       sync.RWMutex
+	getLRPStartAuctionsByProcessGuidArgsForCall []struct {
+		processGuid string
+	}
+	getLRPStartAuctionsByProcessGuidReturns struct {
+		result1 []models.LRPStartAuction
+		result2 error
+	}
+	GetLRPStopAuctionsByProcessGuidStub        func(processGuid string) ([]models.LRPStopAuction, error)
+	getLRPStopAuctionsByProcessGuidMutex       sync.RWMutex
+	getLRPStopAuctionsByProcessGuidArgsForCall []struct {
+		processGuid string
+	}
+	getLRPStopAuctionsByProcessGuidReturns struct {
+		result1 []models.LRPStopAuction
+		result2 error
+	}
+}
+
+func (fake *FakeAuctionBBS) GetLRPStartAuctionsByProcessGuid(processGuid string) ([]models.LRPStartAuction, error) {
+	fake.getLRPStartAuctionsByProcessGuidMutex.Lock()
+	defer fake.getLRPStartAuctionsByProcessGuidMutex.Unlock()
+	fake.getLRPStartAuctionsByProcessGuidArgsForCall = append(fake.getLRPStartAuctionsByProcessGuidArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	if fake.GetLRPStartAuctionsByProcessGuidStub != nil {
+		return fake.GetLRPStartAuctionsByProcessGuidStub(processGuid)
+	} else {
+		return fake.getLRPStartAuctionsByProcessGuidReturns.result1, fake.getLRPStartAuctionsByProcessGuidReturns.result2
+	}
+}
+
+func (fake *FakeAuctionBBS) GetLRPStartAuctionsByProcessGuidCallCount() int {
+	fake.getLRPStartAuctionsByProcessGuidMutex.RLock()
+	defer fake.getLRPStartAuctionsByProcessGuidMutex.RUnlock()
+	return len(fake.getLRPStartAuctionsByProcessGuidArgsForCall)
+}
+
+func (fake *FakeAuctionBBS) GetLRPStartAuctionsByProcessGuidArgsForCall(i int) string {
+	fake.getLRPStartAuctionsByProcessGuidMutex.RLock()
+	defer fake.getLRPStartAuctionsByProcessGuidMutex.RUnlock()
+	return fake.getLRPStartAuctionsByProcessGuidArgsForCall[i].processGuid
+}
+
+func (fake *FakeAuctionBBS) GetLRPStartAuctionsByProcessGuidReturns(result1 []models.LRPStartAuction, result2 error) {
+	fake.GetLRPStartAuctionsByProcessGuidStub = nil
+	fake.getLRPStartAuctionsByProcessGuidReturns = struct {
+		result1 []models.LRPStartAuction
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAuctionBBS) GetLRPStopAuctionsByProcessGuid(processGuid string) ([]models.LRPStopAuction, error) {
+	fake.getLRPStopAuctionsByProcessGuidMutex.Lock()
+	defer fake.getLRPStopAuctionsByProcessGuidMutex.Unlock()
+	fake.getLRPStopAuctionsByProcessGuidArgsForCall = append(fake.getLRPStopAuctionsByProcessGuidArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	if fake.GetLRPStopAuctionsByProcessGuidStub != nil {
+		return fake.GetLRPStopAuctionsByProcessGuidStub(processGuid)
+	} else {
+		return fake.getLRPStopAuctionsByProcessGuidReturns.result1, fake.getLRPStopAuctionsByProcessGuidReturns.result2
+	}
+}
+
+func (fake *FakeAuctionBBS) GetLRPStopAuctionsByProcessGuidCallCount() int {
+	fake.getLRPStopAuctionsByProcessGuidMutex.RLock()
+	defer fake.getLRPStopAuctionsByProcessGuidMutex.RUnlock()
+	return len(fake.getLRPStopAuctionsByProcessGuidArgsForCall)
+}
+
+func (fake *FakeAuctionBBS) GetLRPStopAuctionsByProcessGuidArgsForCall(i int) string {
+	fake.getLRPStopAuctionsByProcessGuidMutex.RLock()
+	defer fake.getLRPStopAuctionsByProcessGuidMutex.RUnlock()
+	return fake.getLRPStopAuctionsByProcessGuidArgsForCall[i].processGuid
+}
+
+func (fake *FakeAuctionBBS) GetLRPStopAuctionsByProcessGuidReturns(result1 []models.LRPStopAuction, result2 error) {
+	fake.GetLRPStopAuctionsByProcessGuidStub = nil
+	fake.getLRPStopAuctionsByProcessGuidReturns = struct {
+		result1 []models.LRPStopAuction
+		result2 error
+	}{result1, result2}
+}
+
+var _ auctions.AuctionBBS = new(FakeAuctionBBS)
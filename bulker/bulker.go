@@ -0,0 +1,105 @@
+package bulker
+
+import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+	"github.com/pivotal-golang/lager"
+)
+
+type BBS interface {
+	GetAllDesiredLRPs() ([]models.DesiredLRP, error)
+	GetAllActualLRPs() ([]models.ActualLRP, error)
+}
+
+type Bulker struct {
+	bbs             BBS
+	processor       processor.Processor
+	pollingInterval time.Duration
+	timeProvider    timeprovider.TimeProvider
+	logger          lager.Logger
+}
+
+func NewBulker(
+	bbs BBS,
+	processor processor.Processor,
+	pollingInterval time.Duration,
+	timeProvider timeprovider.TimeProvider,
+	logger lager.Logger,
+) Bulker {
+	return Bulker{
+		bbs:             bbs,
+		processor:       processor,
+		pollingInterval: pollingInterval,
+		timeProvider:    timeProvider,
+		logger:          logger.Session("bulker"),
+	}
+}
+
+func (b Bulker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := b.timeProvider.NewTickerChannel("bulker", b.pollingInterval)
+
+	close(ready)
+
+	// sync right away, so that whatever was missed while another app manager
+	// held the lock (or none ran at all) is not left until the first tick
+	b.sync()
+
+	for {
+		select {
+		case <-ticker:
+			b.sync()
+
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (b Bulker) sync() {
+	syncLogger := b.logger.Session("sync")
+
+	syncLogger.Info("starting")
+
+	// fetch actuals before desireds: an LRP desired in between is then merely
+	// started twice (and de-duplicated later) rather than stopped as undesired.
+	// The actuals are only used to find processes that are no longer desired;
+	// the processor fetches each process's own actuals when it gets to it, as
+	// this snapshot may be long out of date by then.
+	actualLRPs, err := b.bbs.GetAllActualLRPs()
+	if err != nil {
+		syncLogger.Error("fetch-actuals-failed", err)
+		return
+	}
+
+	desiredLRPs, err := b.bbs.GetAllDesiredLRPs()
+	if err != nil {
+		syncLogger.Error("fetch-desireds-failed", err)
+		return
+	}
+
+	undesired := map[string]bool{}
+	for _, actualLRP := range actualLRPs {
+		undesired[actualLRP.ProcessGuid] = true
+	}
+
+	for _, desiredLRP := range desiredLRPs {
+		b.processor.Reconcile(desiredLRP, desiredLRP.Instances)
+		delete(undesired, desiredLRP.ProcessGuid)
+	}
+
+	for processGuid := range undesired {
+		syncLogger.Info("stopping-undesired", lager.Data{"process-guid": processGuid})
+		b.processor.ProcessDesiredChange(models.DesiredLRPChange{
+			Before: &models.DesiredLRP{ProcessGuid: processGuid},
+		})
+	}
+
+	syncLogger.Info("done", lager.Data{
+		"desired-lrps": len(desiredLRPs),
+		"actual-lrps":  len(actualLRPs),
+	})
+}
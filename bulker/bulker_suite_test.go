@@ -0,0 +1,13 @@
+package bulker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBulker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bulker Suite")
+}
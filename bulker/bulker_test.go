@@ -0,0 +1,144 @@
+package bulker_test
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/bulker"
+	"github.com/cloudfoundry-incubator/app-manager/bulker/fakes"
+	processor_fakes "github.com/cloudfoundry-incubator/app-manager/processor/fakes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Bulker", func() {
+	var (
+		bbs          *fakes.FakeBBS
+		processor    *processor_fakes.FakeProcessor
+		timeProvider *faketimeprovider.FakeTimeProvider
+		logger       *lagertest.TestLogger
+
+		desiredLRPs []models.DesiredLRP
+		actualLRPs  []models.ActualLRP
+
+		bulker ifrit.Process
+	)
+
+	BeforeEach(func() {
+		bbs = new(fakes.FakeBBS)
+		processor = new(processor_fakes.FakeProcessor)
+		timeProvider = faketimeprovider.New(time.Now())
+		timeProvider.ProvideFakeChannels = true
+		logger = lagertest.NewTestLogger("test")
+
+		desiredLRPs = []models.DesiredLRP{
+			{ProcessGuid: "process-guid-1", Instances: 1},
+			{ProcessGuid: "process-guid-2", Instances: 2},
+		}
+
+		actualLRPs = []models.ActualLRP{
+			{ProcessGuid: "process-guid-1", InstanceGuid: "a", Index: 0},
+			{ProcessGuid: "process-guid-2", InstanceGuid: "b", Index: 0},
+			{ProcessGuid: "process-guid-2", InstanceGuid: "c", Index: 1},
+			{ProcessGuid: "undesired-process-guid", InstanceGuid: "d", Index: 0},
+		}
+
+		bbs.GetAllDesiredLRPsReturns(desiredLRPs, nil)
+		bbs.GetAllActualLRPsReturns(actualLRPs, nil)
+	})
+
+	JustBeforeEach(func() {
+		bulker = ifrit.Envoke(NewBulker(bbs, processor, 30*time.Second, timeProvider, logger))
+	})
+
+	AfterEach(func() {
+		bulker.Signal(syscall.SIGINT)
+		Eventually(bulker.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("polls on the configured interval", func() {
+		Ω(timeProvider.TickerDurationFor("bulker")).Should(Equal(30 * time.Second))
+	})
+
+	It("reconciles every desired LRP right away", func() {
+		Eventually(processor.ReconcileCallCount).Should(Equal(2))
+
+		desired, instances := processor.ReconcileArgsForCall(0)
+		Ω(desired).Should(Equal(desiredLRPs[0]))
+		Ω(instances).Should(Equal(1))
+
+		desired, instances = processor.ReconcileArgsForCall(1)
+		Ω(desired).Should(Equal(desiredLRPs[1]))
+		Ω(instances).Should(Equal(2))
+	})
+
+	It("processes the removal of processes whose actuals are no longer desired", func() {
+		Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+
+		Ω(processor.ProcessDesiredChangeArgsForCall(0)).Should(Equal(models.DesiredLRPChange{
+			Before: &models.DesiredLRP{ProcessGuid: "undesired-process-guid"},
+		}))
+	})
+
+	It("does not reconcile again until the polling interval elapses", func() {
+		Eventually(processor.ReconcileCallCount).Should(Equal(2))
+		Consistently(processor.ReconcileCallCount).Should(Equal(2))
+	})
+
+	Context("when the polling interval elapses", func() {
+		JustBeforeEach(func() {
+			Eventually(processor.ReconcileCallCount).Should(Equal(2))
+			timeProvider.TickerChannelFor("bulker") <- time.Now()
+		})
+
+		It("reconciles again", func() {
+			Eventually(processor.ReconcileCallCount).Should(Equal(4))
+		})
+
+		Context("and elapses again", func() {
+			JustBeforeEach(func() {
+				Eventually(processor.ReconcileCallCount).Should(Equal(4))
+				timeProvider.TickerChannelFor("bulker") <- time.Now()
+			})
+
+			It("reconciles again", func() {
+				Eventually(processor.ReconcileCallCount).Should(Equal(6))
+			})
+		})
+	})
+
+	Context("when fetching actuals fails", func() {
+		BeforeEach(func() {
+			bbs.GetAllActualLRPsStub = func() ([]models.ActualLRP, error) {
+				return nil, errors.New("oh no")
+			}
+		})
+
+		It("logs and does not reconcile", func() {
+			Eventually(logger.TestSink.Buffer).Should(gbytes.Say("bulker.sync.fetch-actuals-failed"))
+			Consistently(processor.ReconcileCallCount).Should(BeZero())
+			Ω(processor.ProcessDesiredChangeCallCount()).Should(BeZero())
+		})
+	})
+
+	Context("when fetching desireds fails", func() {
+		BeforeEach(func() {
+			bbs.GetAllDesiredLRPsStub = func() ([]models.DesiredLRP, error) {
+				return nil, errors.New("oh no")
+			}
+		})
+
+		It("logs and does not reconcile anything, so undesired actuals are left alone", func() {
+			Eventually(logger.TestSink.Buffer).Should(gbytes.Say("bulker.sync.fetch-desireds-failed"))
+			Consistently(processor.ReconcileCallCount).Should(BeZero())
+			Ω(processor.ProcessDesiredChangeCallCount()).Should(BeZero())
+		})
+	})
+})
@@ -0,0 +1,82 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/bulker"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeBBS struct {
+	GetAllDesiredLRPsStub        func() ([]models.DesiredLRP, error)
+	getAllDesiredLRPsMutex       sync.RWMutex
+	getAllDesiredLRPsArgsForCall []struct{}
+	getAllDesiredLRPsReturns     struct {
+		result1 []models.DesiredLRP
+		result2 error
+	}
+	GetAllActualLRPsStub        func() ([]models.ActualLRP, error)
+	getAllActualLRPsMutex       sync.RWMutex
+	getAllActualLRPsArgsForCall []struct{}
+	getAllActualLRPsReturns     struct {
+		result1 []models.ActualLRP
+		result2 error
+	}
+}
+
+func (fake *FakeBBS) GetAllDesiredLRPs() ([]models.DesiredLRP, error) {
+	fake.getAllDesiredLRPsMutex.Lock()
+	defer fake.getAllDesiredLRPsMutex.Unlock()
+	fake.getAllDesiredLRPsArgsForCall = append(fake.getAllDesiredLRPsArgsForCall, struct{}{})
+	if fake.GetAllDesiredLRPsStub != nil {
+		return fake.GetAllDesiredLRPsStub()
+	} else {
+		return fake.getAllDesiredLRPsReturns.result1, fake.getAllDesiredLRPsReturns.result2
+	}
+}
+
+func (fake *FakeBBS) GetAllDesiredLRPsCallCount() int {
+	fake.getAllDesiredLRPsMutex.RLock()
+	defer fake.getAllDesiredLRPsMutex.RUnlock()
+	return len(fake.getAllDesiredLRPsArgsForCall)
+}
+
+func (fake *FakeBBS) GetAllDesiredLRPsReturns(result1 []models.DesiredLRP, result2 error) {
+	fake.getAllDesiredLRPsMutex.Lock()
+	defer fake.getAllDesiredLRPsMutex.Unlock()
+	fake.GetAllDesiredLRPsStub = nil
+	fake.getAllDesiredLRPsReturns = struct {
+		result1 []models.DesiredLRP
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBBS) GetAllActualLRPs() ([]models.ActualLRP, error) {
+	fake.getAllActualLRPsMutex.Lock()
+	defer fake.getAllActualLRPsMutex.Unlock()
+	fake.getAllActualLRPsArgsForCall = append(fake.getAllActualLRPsArgsForCall, struct{}{})
+	if fake.GetAllActualLRPsStub != nil {
+		return fake.GetAllActualLRPsStub()
+	} else {
+		return fake.getAllActualLRPsReturns.result1, fake.getAllActualLRPsReturns.result2
+	}
+}
+
+func (fake *FakeBBS) GetAllActualLRPsCallCount() int {
+	fake.getAllActualLRPsMutex.RLock()
+	defer fake.getAllActualLRPsMutex.RUnlock()
+	return len(fake.getAllActualLRPsArgsForCall)
+}
+
+func (fake *FakeBBS) GetAllActualLRPsReturns(result1 []models.ActualLRP, result2 error) {
+	fake.getAllActualLRPsMutex.Lock()
+	defer fake.getAllActualLRPsMutex.Unlock()
+	fake.GetAllActualLRPsStub = nil
+	fake.getAllActualLRPsReturns = struct {
+		result1 []models.ActualLRP
+		result2 error
+	}{result1, result2}
+}
+
+var _ bulker.BBS = new(FakeBBS)
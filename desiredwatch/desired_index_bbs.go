@@ -0,0 +1,35 @@
+package desiredwatch
+
+import (
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry/storeadapter"
+)
+
+// A DesiredIndexBBS looks up the modified index of the desired node of a
+// process, which changes with every version of the desired LRP but survives
+// restarts of the app manager.
+type DesiredIndexBBS interface {
+	GetDesiredLRPIndex(processGuid string) (uint64, error)
+}
+
+type desiredIndexBBS struct {
+	store storeadapter.StoreAdapter
+}
+
+func NewDesiredIndexBBS(store storeadapter.StoreAdapter) DesiredIndexBBS {
+	return &desiredIndexBBS{
+		store: store,
+	}
+}
+
+// GetDesiredLRPIndex returns 0 for a process that is no longer desired.
+func (bbs *desiredIndexBBS) GetDesiredLRPIndex(processGuid string) (uint64, error) {
+	node, err := bbs.store.Get(shared.DesiredLRPSchemaPathByProcessGuid(processGuid))
+	if err == storeadapter.ErrorKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return node.Index, nil
+}
@@ -0,0 +1,54 @@
+package desiredwatch_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DesiredIndexBBS", func() {
+	var (
+		store *fakestoreadapter.FakeStoreAdapter
+		bbs   DesiredIndexBBS
+	)
+
+	BeforeEach(func() {
+		store = fakestoreadapter.New()
+		bbs = NewDesiredIndexBBS(store)
+	})
+
+	It("returns the modified index of the desired node of the process", func() {
+		err := store.SetMulti([]storeadapter.StoreNode{
+			{
+				Key:   shared.DesiredLRPSchemaPathByProcessGuid("some-process-guid"),
+				Value: []byte("{}"),
+				Index: 42,
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		index, err := bbs.GetDesiredLRPIndex("some-process-guid")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(index).Should(Equal(uint64(42)))
+	})
+
+	It("returns 0 for a process that is not desired", func() {
+		index, err := bbs.GetDesiredLRPIndex("some-process-guid")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(index).Should(BeZero())
+	})
+
+	It("returns an error if the store cannot be read", func() {
+		disaster := errors.New("oh no")
+		store.GetErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector(".*", disaster)
+
+		_, err := bbs.GetDesiredLRPIndex("some-process-guid")
+		Ω(err).Should(Equal(disaster))
+	})
+})
@@ -0,0 +1,78 @@
+package desiredwatch
+
+import (
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+)
+
+// A DesiredLRPChange is a change to a desired LRP along with the modified
+// index of the desired node it concerns. When the node is removed, that is
+// the index of the version that was removed.
+type DesiredLRPChange struct {
+	models.DesiredLRPChange
+
+	Index uint64
+}
+
+// Removed reports whether the change removed the desired LRP.
+func (change DesiredLRPChange) Removed() bool {
+	return change.After == nil
+}
+
+// A DesiredWatchBBS watches desired LRPs like the BBS does, but keeps track
+// of the modified index of each change so that old changes can be told
+// apart from new ones.
+type DesiredWatchBBS interface {
+	WatchForDesiredLRPChanges() (<-chan DesiredLRPChange, chan<- bool, <-chan error)
+}
+
+type desiredWatchBBS struct {
+	store storeadapter.StoreAdapter
+}
+
+func NewDesiredWatchBBS(store storeadapter.StoreAdapter) DesiredWatchBBS {
+	return &desiredWatchBBS{
+		store: store,
+	}
+}
+
+func (bbs *desiredWatchBBS) WatchForDesiredLRPChanges() (<-chan DesiredLRPChange, chan<- bool, <-chan error) {
+	desired := make(chan DesiredLRPChange)
+
+	filter := func(event storeadapter.WatchEvent) (DesiredLRPChange, bool) {
+		var change DesiredLRPChange
+
+		if event.Node != nil {
+			after, err := models.NewDesiredLRPFromJSON(event.Node.Value)
+			if err != nil {
+				return DesiredLRPChange{}, false
+			}
+
+			change.After = &after
+			change.Index = event.Node.Index
+		}
+
+		if event.PrevNode != nil && len(event.PrevNode.Value) > 0 {
+			before, err := models.NewDesiredLRPFromJSON(event.PrevNode.Value)
+			if err != nil {
+				return DesiredLRPChange{}, false
+			}
+
+			change.Before = &before
+			if change.After == nil {
+				change.Index = event.PrevNode.Index
+			}
+		}
+
+		if change.Before == nil && change.After == nil {
+			return DesiredLRPChange{}, false
+		}
+
+		return change, true
+	}
+
+	stop, err := shared.WatchWithFilter(bbs.store, shared.DesiredLRPSchemaRoot, desired, filter)
+
+	return desired, stop, err
+}
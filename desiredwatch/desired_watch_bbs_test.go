@@ -0,0 +1,108 @@
+package desiredwatch_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DesiredWatchBBS", func() {
+	var (
+		store      *fakestoreadapter.FakeStoreAdapter
+		desiredLRP models.DesiredLRP
+
+		changes <-chan DesiredLRPChange
+	)
+
+	setDesired := func(lrp models.DesiredLRP, index uint64) {
+		err := store.SetMulti([]storeadapter.StoreNode{
+			{
+				Key:   shared.DesiredLRPSchemaPath(lrp),
+				Value: lrp.ToJSON(),
+				Index: index,
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		store = fakestoreadapter.New()
+
+		desiredLRP = models.DesiredLRP{
+			ProcessGuid: "some-process-guid",
+			Stack:       "some-stack",
+			Instances:   1,
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.RunAction{
+						Path: "some-path",
+					},
+				},
+			},
+		}
+
+		changes, _, _ = NewDesiredWatchBBS(store).WatchForDesiredLRPChanges()
+	})
+
+	It("sends desired LRPs that are created along with their modified index", func() {
+		setDesired(desiredLRP, 5)
+
+		var change DesiredLRPChange
+		Eventually(changes).Should(Receive(&change))
+		Ω(change.Before).Should(BeNil())
+		Ω(change.After).Should(Equal(&desiredLRP))
+		Ω(change.Index).Should(Equal(uint64(5)))
+		Ω(change.Removed()).Should(BeFalse())
+	})
+
+	Context("when the desired LRP already exists", func() {
+		var changedLRP models.DesiredLRP
+
+		BeforeEach(func() {
+			setDesired(desiredLRP, 5)
+			Eventually(changes).Should(Receive())
+
+			changedLRP = desiredLRP
+			changedLRP.Instances = 2
+		})
+
+		It("sends changes to it along with their modified index", func() {
+			setDesired(changedLRP, 7)
+
+			var change DesiredLRPChange
+			Eventually(changes).Should(Receive(&change))
+			Ω(change.Before).Should(Equal(&desiredLRP))
+			Ω(change.After).Should(Equal(&changedLRP))
+			Ω(change.Index).Should(Equal(uint64(7)))
+		})
+
+		It("sends its removal along with the index of the version that was removed", func() {
+			err := store.Delete(shared.DesiredLRPSchemaPath(desiredLRP))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var change DesiredLRPChange
+			Eventually(changes).Should(Receive(&change))
+			Ω(change.Before).Should(Equal(&desiredLRP))
+			Ω(change.After).Should(BeNil())
+			Ω(change.Index).Should(Equal(uint64(5)))
+			Ω(change.Removed()).Should(BeTrue())
+		})
+	})
+
+	It("does not send changes it cannot make sense of", func() {
+		err := store.SetMulti([]storeadapter.StoreNode{
+			{
+				Key:   shared.DesiredLRPSchemaPathByProcessGuid("some-process-guid"),
+				Value: []byte("ß"),
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Consistently(changes).ShouldNot(Receive())
+	})
+})
@@ -0,0 +1,13 @@
+package desiredwatch_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDesiredWatch(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DesiredWatch Suite")
+}
@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+)
+
+type FakeDesiredIndexBBS struct {
+	GetDesiredLRPIndexStub        func(processGuid string) (uint64, error)
+	getDesiredLRPIndexMutex       sync.RWMutex
+	getDesiredLRPIndexArgsForCall []struct {
+		processGuid string
+	}
+	getDesiredLRPIndexReturns struct {
+		result1 uint64
+		result2 error
+	}
+}
+
+func (fake *FakeDesiredIndexBBS) GetDesiredLRPIndex(processGuid string) (uint64, error) {
+	fake.getDesiredLRPIndexMutex.Lock()
+	defer fake.getDesiredLRPIndexMutex.Unlock()
+	fake.getDesiredLRPIndexArgsForCall = append(fake.getDesiredLRPIndexArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	if fake.GetDesiredLRPIndexStub != nil {
+		return fake.GetDesiredLRPIndexStub(processGuid)
+	} else {
+		return fake.getDesiredLRPIndexReturns.result1, fake.getDesiredLRPIndexReturns.result2
+	}
+}
+
+func (fake *FakeDesiredIndexBBS) GetDesiredLRPIndexCallCount() int {
+	fake.getDesiredLRPIndexMutex.RLock()
+	defer fake.getDesiredLRPIndexMutex.RUnlock()
+	return len(fake.getDesiredLRPIndexArgsForCall)
+}
+
+func (fake *FakeDesiredIndexBBS) GetDesiredLRPIndexArgsForCall(i int) string {
+	fake.getDesiredLRPIndexMutex.RLock()
+	defer fake.getDesiredLRPIndexMutex.RUnlock()
+	return fake.getDesiredLRPIndexArgsForCall[i].processGuid
+}
+
+func (fake *FakeDesiredIndexBBS) GetDesiredLRPIndexReturns(result1 uint64, result2 error) {
+	fake.getDesiredLRPIndexMutex.Lock()
+	defer fake.getDesiredLRPIndexMutex.Unlock()
+	fake.getDesiredLRPIndexReturns = struct {
+		result1 uint64
+		result2 error
+	}{result1, result2}
+}
+
+var _ desiredwatch.DesiredIndexBBS = new(FakeDesiredIndexBBS)
@@ -0,0 +1,58 @@
+package dryrun
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// dryRunBBS reads from the BBS it wraps, but only logs and counts the
+// requests it would have written.
+type dryRunBBS struct {
+	Bbs.AppManagerBBS
+
+	emitter metrics.Emitter
+	logger  lager.Logger
+}
+
+func New(bbs Bbs.AppManagerBBS, emitter metrics.Emitter, logger lager.Logger) Bbs.AppManagerBBS {
+	return &dryRunBBS{
+		AppManagerBBS: bbs,
+
+		emitter: emitter,
+		logger:  logger.Session("dry-run"),
+	}
+}
+
+func (bbs *dryRunBBS) RequestLRPStartAuction(lrp models.LRPStartAuction) error {
+	bbs.logger.Info("would-start", lager.Data{
+		"process-guid":  lrp.DesiredLRP.ProcessGuid,
+		"index":         lrp.Index,
+		"instance-guid": lrp.InstanceGuid,
+	})
+	bbs.emitter.IncrementCounter(metrics.DryRunStarts)
+
+	return nil
+}
+
+func (bbs *dryRunBBS) RequestStopLRPInstance(stopInstance models.StopLRPInstance) error {
+	bbs.logger.Info("would-stop", lager.Data{
+		"process-guid":  stopInstance.ProcessGuid,
+		"index":         stopInstance.Index,
+		"instance-guid": stopInstance.InstanceGuid,
+	})
+	bbs.emitter.IncrementCounter(metrics.DryRunStopInstances)
+
+	return nil
+}
+
+func (bbs *dryRunBBS) RequestLRPStopAuction(stopAuction models.LRPStopAuction) error {
+	bbs.logger.Info("would-stop-duplicates", lager.Data{
+		"process-guid": stopAuction.ProcessGuid,
+		"index":        stopAuction.Index,
+	})
+	bbs.emitter.IncrementCounter(metrics.DryRunStopAuctions)
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package dryrun_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/dryrun"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	metrics_fakes "github.com/cloudfoundry-incubator/app-manager/metrics/fakes"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fake_bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("DryRunBBS", func() {
+	var (
+		bbs     *fake_bbs.FakeAppManagerBBS
+		emitter *metrics_fakes.FakeEmitter
+		logger  *lagertest.TestLogger
+
+		dryRunBBS Bbs.AppManagerBBS
+	)
+
+	BeforeEach(func() {
+		bbs = fake_bbs.NewFakeAppManagerBBS()
+		emitter = metrics_fakes.NewFakeEmitter()
+		logger = lagertest.NewTestLogger("test")
+
+		dryRunBBS = New(bbs, emitter, logger)
+	})
+
+	It("reads from the wrapped BBS", func() {
+		bbs.ActualLRPs = []models.ActualLRP{
+			{ProcessGuid: "some-process-guid", InstanceGuid: "some-instance-guid"},
+		}
+
+		actualLRPs, err := dryRunBBS.GetActualLRPsByProcessGuid("some-process-guid")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(actualLRPs).Should(Equal(bbs.ActualLRPs))
+	})
+
+	Describe("requesting a start auction", func() {
+		BeforeEach(func() {
+			err := dryRunBBS.RequestLRPStartAuction(models.LRPStartAuction{
+				DesiredLRP:   models.DesiredLRP{ProcessGuid: "some-process-guid"},
+				Index:        1,
+				InstanceGuid: "some-instance-guid",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("does not write it", func() {
+			Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+		})
+
+		It("logs and counts it", func() {
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.dry-run.would-start"))
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say(`"instance-guid":"some-instance-guid"`))
+			Ω(emitter.Counter(metrics.DryRunStarts)).Should(Equal(1))
+		})
+	})
+
+	Describe("requesting an instance be stopped", func() {
+		BeforeEach(func() {
+			err := dryRunBBS.RequestStopLRPInstance(models.StopLRPInstance{
+				ProcessGuid:  "some-process-guid",
+				Index:        1,
+				InstanceGuid: "some-instance-guid",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("does not write it", func() {
+			Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+		})
+
+		It("logs and counts it", func() {
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.dry-run.would-stop"))
+			Ω(emitter.Counter(metrics.DryRunStopInstances)).Should(Equal(1))
+		})
+	})
+
+	Describe("requesting a stop auction", func() {
+		BeforeEach(func() {
+			err := dryRunBBS.RequestLRPStopAuction(models.LRPStopAuction{
+				ProcessGuid: "some-process-guid",
+				Index:       1,
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("does not write it", func() {
+			Ω(bbs.GetLRPStopAuctions()).Should(BeEmpty())
+		})
+
+		It("logs and counts it", func() {
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.dry-run.would-stop-duplicates"))
+			Ω(emitter.Counter(metrics.DryRunStopAuctions)).Should(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/freshness"
+)
+
+type FakeFreshnessBBS struct {
+	BumpFreshnessStub        func(domain string, ttl time.Duration) error
+	bumpFreshnessMutex       sync.RWMutex
+	bumpFreshnessArgsForCall []struct {
+		domain string
+		ttl    time.Duration
+	}
+	bumpFreshnessReturns struct {
+		result1 error
+	}
+	IsFreshStub        func(domain string) (bool, error)
+	isFreshMutex       sync.RWMutex
+	isFreshArgsForCall []struct {
+		domain string
+	}
+	isFreshReturns struct {
+		result1 bool
+		result2 error
+	}
+}
+
+func (fake *FakeFreshnessBBS) BumpFreshness(domain string, ttl time.Duration) error {
+	fake.bumpFreshnessMutex.Lock()
+	defer fake.bumpFreshnessMutex.Unlock()
+	fake.bumpFreshnessArgsForCall = append(fake.bumpFreshnessArgsForCall, struct {
+		domain string
+		ttl    time.Duration
+	}{domain, ttl})
+	if fake.BumpFreshnessStub != nil {
+		return fake.BumpFreshnessStub(domain, ttl)
+	} else {
+		return fake.bumpFreshnessReturns.result1
+	}
+}
+
+func (fake *FakeFreshnessBBS) BumpFreshnessCallCount() int {
+	fake.bumpFreshnessMutex.RLock()
+	defer fake.bumpFreshnessMutex.RUnlock()
+	return len(fake.bumpFreshnessArgsForCall)
+}
+
+func (fake *FakeFreshnessBBS) BumpFreshnessArgsForCall(i int) (string, time.Duration) {
+	fake.bumpFreshnessMutex.RLock()
+	defer fake.bumpFreshnessMutex.RUnlock()
+	return fake.bumpFreshnessArgsForCall[i].domain, fake.bumpFreshnessArgsForCall[i].ttl
+}
+
+func (fake *FakeFreshnessBBS) BumpFreshnessReturns(result1 error) {
+	fake.BumpFreshnessStub = nil
+	fake.bumpFreshnessReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeFreshnessBBS) IsFresh(domain string) (bool, error) {
+	fake.isFreshMutex.Lock()
+	defer fake.isFreshMutex.Unlock()
+	fake.isFreshArgsForCall = append(fake.isFreshArgsForCall, struct {
+		domain string
+	}{domain})
+	if fake.IsFreshStub != nil {
+		return fake.IsFreshStub(domain)
+	} else {
+		return fake.isFreshReturns.result1, fake.isFreshReturns.result2
+	}
+}
+
+func (fake *FakeFreshnessBBS) IsFreshCallCount() int {
+	fake.isFreshMutex.RLock()
+	defer fake.isFreshMutex.RUnlock()
+	return len(fake.isFreshArgsForCall)
+}
+
+func (fake *FakeFreshnessBBS) IsFreshArgsForCall(i int) string {
+	fake.isFreshMutex.RLock()
+	defer fake.isFreshMutex.RUnlock()
+	return fake.isFreshArgsForCall[i].domain
+}
+
+func (fake *FakeFreshnessBBS) IsFreshReturns(result1 bool, result2 error) {
+	fake.IsFreshStub = nil
+	fake.isFreshReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+var _ freshness.FreshnessBBS = new(FakeFreshnessBBS)
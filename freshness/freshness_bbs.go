@@ -0,0 +1,61 @@
+package freshness
+
+import (
+	"path"
+	"time"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry/storeadapter"
+)
+
+const DomainSchemaRoot = shared.SchemaRoot + "domain"
+
+func DomainSchemaPath(domain string) string {
+	return path.Join(DomainSchemaRoot, domain)
+}
+
+// A domain is fresh while whoever desires its LRPs keeps bumping it. Until
+// then, its desired LRPs going away may mean they were lost rather than
+// removed on purpose.
+type FreshnessBBS interface {
+	BumpFreshness(domain string, ttl time.Duration) error
+	IsFresh(domain string) (bool, error)
+}
+
+type freshnessBBS struct {
+	store storeadapter.StoreAdapter
+}
+
+func NewFreshnessBBS(store storeadapter.StoreAdapter) FreshnessBBS {
+	return &freshnessBBS{
+		store: store,
+	}
+}
+
+func (bbs *freshnessBBS) BumpFreshness(domain string, ttl time.Duration) error {
+	return shared.RetryIndefinitelyOnStoreTimeout(func() error {
+		return bbs.store.SetMulti([]storeadapter.StoreNode{
+			{
+				Key: DomainSchemaPath(domain),
+				TTL: uint64(ttl.Seconds()),
+			},
+		})
+	})
+}
+
+func (bbs *freshnessBBS) IsFresh(domain string) (bool, error) {
+	if domain == "" {
+		return false, nil
+	}
+
+	_, err := bbs.store.Get(DomainSchemaPath(domain))
+	if err == storeadapter.ErrorKeyNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
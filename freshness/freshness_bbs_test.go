@@ -0,0 +1,69 @@
+package freshness_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/freshness"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FreshnessBBS", func() {
+	var (
+		store *fakestoreadapter.FakeStoreAdapter
+		bbs   FreshnessBBS
+	)
+
+	BeforeEach(func() {
+		store = fakestoreadapter.New()
+		bbs = NewFreshnessBBS(store)
+	})
+
+	Describe("BumpFreshness", func() {
+		It("sets the domain's key with the given ttl", func() {
+			err := bbs.BumpFreshness("some-domain", 2*time.Minute)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			node, err := store.Get("/v1/domain/some-domain")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.TTL).Should(Equal(uint64(120)))
+		})
+	})
+
+	Describe("IsFresh", func() {
+		It("is true once the domain has been bumped", func() {
+			err := bbs.BumpFreshness("some-domain", time.Minute)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			fresh, err := bbs.IsFresh("some-domain")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fresh).Should(BeTrue())
+		})
+
+		It("is false for a domain that has not been bumped", func() {
+			fresh, err := bbs.IsFresh("some-domain")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fresh).Should(BeFalse())
+		})
+
+		It("is false when the domain is not known", func() {
+			fresh, err := bbs.IsFresh("")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fresh).Should(BeFalse())
+		})
+
+		Context("when the store fails", func() {
+			BeforeEach(func() {
+				store.GetErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector("domain", errors.New("oops"))
+			})
+
+			It("returns the error", func() {
+				_, err := bbs.IsFresh("some-domain")
+				Ω(err).Should(MatchError("oops"))
+			})
+		})
+	})
+})
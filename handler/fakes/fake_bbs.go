@@ -0,0 +1,80 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/app-manager/handler"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeBBS struct {
+	DesiredLRPChangeChan chan desiredwatch.DesiredLRPChange
+	DesiredLRPStopChan   chan bool
+	DesiredLRPErrChan    chan error
+
+	ActualLRPChangeChan chan models.ActualLRPChange
+	ActualLRPStopChan   chan bool
+	ActualLRPErrChan    chan error
+
+	WhenGettingDesiredLRPByProcessGuid func(processGuid string) (models.DesiredLRP, error)
+	getDesiredLRPProcessGuids          []string
+	actualLRPs                         []models.ActualLRP
+
+	sync.RWMutex
+}
+
+func NewFakeBBS() *FakeBBS {
+	return &FakeBBS{
+		DesiredLRPChangeChan: make(chan desiredwatch.DesiredLRPChange, 1),
+		DesiredLRPStopChan:   make(chan bool),
+		DesiredLRPErrChan:    make(chan error),
+
+		ActualLRPChangeChan: make(chan models.ActualLRPChange, 1),
+		ActualLRPStopChan:   make(chan bool),
+		ActualLRPErrChan:    make(chan error),
+	}
+}
+
+func (fakeBBS *FakeBBS) WatchForDesiredLRPChanges() (<-chan desiredwatch.DesiredLRPChange, chan<- bool, <-chan error) {
+	return fakeBBS.DesiredLRPChangeChan, fakeBBS.DesiredLRPStopChan, fakeBBS.DesiredLRPErrChan
+}
+
+func (fakeBBS *FakeBBS) WatchForActualLRPChanges() (<-chan models.ActualLRPChange, chan<- bool, <-chan error) {
+	return fakeBBS.ActualLRPChangeChan, fakeBBS.ActualLRPStopChan, fakeBBS.ActualLRPErrChan
+}
+
+func (fakeBBS *FakeBBS) GetDesiredLRPByProcessGuid(processGuid string) (models.DesiredLRP, error) {
+	fakeBBS.Lock()
+	fakeBBS.getDesiredLRPProcessGuids = append(fakeBBS.getDesiredLRPProcessGuids, processGuid)
+	fakeBBS.Unlock()
+
+	if fakeBBS.WhenGettingDesiredLRPByProcessGuid != nil {
+		return fakeBBS.WhenGettingDesiredLRPByProcessGuid(processGuid)
+	}
+
+	return models.DesiredLRP{}, nil
+}
+
+func (fakeBBS *FakeBBS) GetActualLRPsByProcessGuid(processGuid string) ([]models.ActualLRP, error) {
+	fakeBBS.RLock()
+	defer fakeBBS.RUnlock()
+
+	return fakeBBS.actualLRPs, nil
+}
+
+func (fakeBBS *FakeBBS) SetActualLRPs(actualLRPs []models.ActualLRP) {
+	fakeBBS.Lock()
+	defer fakeBBS.Unlock()
+
+	fakeBBS.actualLRPs = actualLRPs
+}
+
+func (fakeBBS *FakeBBS) GetDesiredLRPProcessGuids() []string {
+	fakeBBS.RLock()
+	defer fakeBBS.RUnlock()
+	return fakeBBS.getDesiredLRPProcessGuids
+}
+
+var _ handler.BBS = new(FakeBBS)
+var _ desiredwatch.DesiredWatchBBS = new(FakeBBS)
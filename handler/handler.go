@@ -1,76 +1,154 @@
 package handler
 
 import (
-	"errors"
 	"os"
 	"sync"
+	"time"
 
-	"github.com/cloudfoundry-incubator/delta_force/delta_force"
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/app-manager/workqueue"
 	"github.com/cloudfoundry-incubator/runtime-schema/models"
-	"github.com/nu7hatch/gouuid"
+	"github.com/cloudfoundry/storeadapter"
 	"github.com/pivotal-golang/lager"
 )
 
-var ErrNoHealthCheckDefined = errors.New("no health check defined for stack")
-
-type LRPreProcessor interface {
-	PreProcess(lrp models.DesiredLRP, instanceIndex int, instanceGuid string) (models.DesiredLRP, error)
+type BBS interface {
+	WatchForActualLRPChanges() (<-chan models.ActualLRPChange, chan<- bool, <-chan error)
+	GetDesiredLRPByProcessGuid(processGuid string) (models.DesiredLRP, error)
+	GetActualLRPsByProcessGuid(processGuid string) ([]models.ActualLRP, error)
 }
 
 type Handler struct {
-	bbs            Bbs.AppManagerBBS
-	lrPreProcessor LRPreProcessor
-	logger         lager.Logger
+	bbs                      BBS
+	desiredWatchBBS          desiredwatch.DesiredWatchBBS
+	processor                processor.Processor
+	registry                 status.Registry
+	emitter                  metrics.Emitter
+	schedulable              <-chan string
+	startable                <-chan string
+	workers                  int
+	actualChangeDebounceTime time.Duration
+	logger                   lager.Logger
 }
 
 func NewHandler(
-	bbs Bbs.AppManagerBBS,
-	lrPreProcessor LRPreProcessor,
+	bbs BBS,
+	desiredWatchBBS desiredwatch.DesiredWatchBBS,
+	processor processor.Processor,
+	registry status.Registry,
+	emitter metrics.Emitter,
+	schedulable <-chan string,
+	startable <-chan string,
+	workers int,
+	actualChangeDebounceTime time.Duration,
 	logger lager.Logger,
 ) Handler {
 	handlerLogger := logger.Session("handler")
 	return Handler{
-		bbs:            bbs,
-		lrPreProcessor: lrPreProcessor,
-		logger:         handlerLogger,
+		bbs:                      bbs,
+		desiredWatchBBS:          desiredWatchBBS,
+		processor:                processor,
+		registry:                 registry,
+		emitter:                  emitter,
+		schedulable:              schedulable,
+		startable:                startable,
+		workers:                  workers,
+		actualChangeDebounceTime: actualChangeDebounceTime,
+		logger:                   handlerLogger,
 	}
 }
 
 func (h Handler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
-	wg := new(sync.WaitGroup)
-	desiredChangeChan, stopChan, errChan := h.bbs.WatchForDesiredLRPChanges()
+	queue := workqueue.New(h.workers)
+	pending := &pendingChanges{
+		changes:    map[string]desiredwatch.DesiredLRPChange{},
+		processing: map[string]uint64{},
+	}
+	desiredChangeChan, desiredStopChan, desiredErrChan := h.desiredWatchBBS.WatchForDesiredLRPChanges()
+	h.registry.SetDesiredWatchEstablished(true)
+	actualChangeChan, actualStopChan, actualErrChan := h.bbs.WatchForActualLRPChanges()
+
+	debouncing := map[string]bool{}
+	debounced := make(chan string)
+	shuttingDown := make(chan struct{})
 
 	close(ready)
 
 	for {
 		if desiredChangeChan == nil {
-			desiredChangeChan, stopChan, errChan = h.bbs.WatchForDesiredLRPChanges()
+			h.emitter.IncrementCounter(metrics.WatchReconnects)
+			desiredChangeChan, desiredStopChan, desiredErrChan = h.desiredWatchBBS.WatchForDesiredLRPChanges()
+			h.registry.SetDesiredWatchEstablished(true)
+		}
+
+		if actualChangeChan == nil {
+			h.emitter.IncrementCounter(metrics.WatchReconnects)
+			actualChangeChan, actualStopChan, actualErrChan = h.bbs.WatchForActualLRPChanges()
 		}
 
 		select {
 		case desiredChange, ok := <-desiredChangeChan:
 			if ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					h.processDesiredChange(desiredChange)
-				}()
+				processGuid := desiredProcessGuidOf(desiredChange.DesiredLRPChange)
+				if h.stale(processGuid, desiredChange, pending) {
+					continue
+				}
+
+				pending.add(processGuid, desiredChange)
+				queue.Enqueue(processGuid, h.processFunc(processGuid, pending))
 			} else {
 				h.logger.Error("watch-closed", nil)
+				h.registry.SetDesiredWatchEstablished(false)
 				desiredChangeChan = nil
 			}
 
-		case err, ok := <-errChan:
+		case err, ok := <-desiredErrChan:
 			if ok {
 				h.logger.Error("watch-error", err)
 			}
+			h.registry.SetDesiredWatchEstablished(false)
 			desiredChangeChan = nil
 
+		case actualChange, ok := <-actualChangeChan:
+			if ok {
+				if requiresReconcile(actualChange) {
+					processGuid := processGuidOf(actualChange)
+					if !debouncing[processGuid] {
+						debouncing[processGuid] = true
+						go h.debounce(processGuid, debounced, shuttingDown)
+					}
+				}
+			} else {
+				h.logger.Error("actual-watch-closed", nil)
+				actualChangeChan = nil
+			}
+
+		case err, ok := <-actualErrChan:
+			if ok {
+				h.logger.Error("actual-watch-error", err)
+			}
+			actualChangeChan = nil
+
+		case processGuid := <-debounced:
+			delete(debouncing, processGuid)
+			queue.Enqueue(processGuid, h.processFunc(processGuid, pending))
+
+		case processGuid := <-h.schedulable:
+			queue.Enqueue(processGuid, h.processFunc(processGuid, pending))
+
+		case processGuid := <-h.startable:
+			queue.Enqueue(processGuid, h.processFunc(processGuid, pending))
+
 		case <-signals:
 			h.logger.Info("shutting-down")
-			close(stopChan)
-			wg.Wait()
+			close(shuttingDown)
+			close(desiredStopChan)
+			h.registry.SetDesiredWatchEstablished(false)
+			close(actualStopChan)
+			queue.Wait()
 			h.logger.Info("shut-down")
 			return nil
 		}
@@ -79,118 +157,229 @@ func (h Handler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	return nil
 }
 
-func (h Handler) processDesiredChange(desiredChange models.DesiredLRPChange) {
-	var desiredLRP models.DesiredLRP
-	var desiredInstances int
+func (h Handler) debounce(processGuid string, debounced chan<- string, shuttingDown <-chan struct{}) {
+	select {
+	case <-time.After(h.actualChangeDebounceTime):
+		select {
+		case debounced <- processGuid:
+		case <-shuttingDown:
+		}
+	case <-shuttingDown:
+	}
+}
+
+// processFunc returns the work to do for a process guid: its pending desired
+// change, if there is one, or else a reconcile against the current desired
+// LRP. Either brings its actual LRPs in line.
+//
+// A change is only recorded as processed once the processor has brought it
+// about, so that it is not taken to be stale should it be seen again.
+func (h Handler) processFunc(processGuid string, pending *pendingChanges) func() {
+	return func() {
+		desiredChange, ok := pending.take(processGuid)
+		if !ok {
+			h.reconcileProcessGuid(processGuid, pending)
+			return
+		}
 
-	changeLogger := h.logger.Session("desired-lrp-change")
+		defer pending.done(processGuid)
 
-	if desiredChange.After == nil {
-		desiredLRP = *desiredChange.Before
-		desiredInstances = 0
-	} else {
-		desiredLRP = *desiredChange.After
-		desiredInstances = desiredLRP.Instances
+		err := h.processor.ProcessDesiredChange(desiredChange.DesiredLRPChange)
+		if err != nil {
+			h.logger.Error("process-desired-change-failed", err, lager.Data{
+				"process-guid": processGuid,
+				"index":        desiredChange.Index,
+			})
+			return
+		}
+
+		h.registry.RecordProcessedIndex(processGuid, desiredChange.Index)
+
+		if desiredChange.Removed() {
+			h.forgetIfGone(h.logger, processGuid, pending)
+		}
+	}
+}
+
+// stale reports whether a desired change is older than the last one that was
+// processed for the process guid, or than one that is waiting to be or being
+// processed. Removing the version that came last is not stale.
+func (h Handler) stale(processGuid string, desiredChange desiredwatch.DesiredLRPChange, pending *pendingChanges) bool {
+	newestIndex, ok := h.registry.ProcessedIndex(processGuid)
+
+	pendingIndex, pendingOk := pending.newest(processGuid)
+	if pendingOk && (!ok || pendingIndex > newestIndex) {
+		newestIndex, ok = pendingIndex, true
+	}
+
+	if !ok {
+		return false
+	}
+
+	if desiredChange.Index > newestIndex || desiredChange.Index == newestIndex && desiredChange.Removed() {
+		return false
+	}
+
+	h.logger.Info("ignoring-stale-desired-change", lager.Data{
+		"process-guid": processGuid,
+		"index":        desiredChange.Index,
+		"newest-index": newestIndex,
+	})
+	h.emitter.IncrementCounter(metrics.StaleDesiredChanges)
+
+	return true
+}
+
+func (h Handler) reconcileProcessGuid(processGuid string, pending *pendingChanges) {
+	reconcileLogger := h.logger.Session("actual-lrp-change", lager.Data{"process-guid": processGuid})
+
+	desiredLRP, err := h.bbs.GetDesiredLRPByProcessGuid(processGuid)
+	if err == storeadapter.ErrorKeyNotFound {
+		reconcileLogger.Info("desired-lrp-not-found")
+		h.forgetIfGone(reconcileLogger, processGuid, pending)
+		return
 	}
 
-	actualInstances, instanceGuidToActual, err := h.actualsForProcessGuid(desiredLRP.ProcessGuid)
 	if err != nil {
-		changeLogger.Error("fetch-actuals-failed", err, lager.Data{"desired-app-message": desiredLRP})
+		reconcileLogger.Error("fetch-desired-failed", err)
 		return
 	}
 
-	delta := delta_force.Reconcile(desiredInstances, actualInstances)
+	h.processor.ProcessDesiredChange(models.DesiredLRPChange{
+		Before: &desiredLRP,
+		After:  &desiredLRP,
+	})
+}
 
-	for _, lrpIndex := range delta.IndicesToStart {
-		changeLogger.Info("request-start", lager.Data{
-			"desired-app-message": desiredLRP,
-			"index":               lrpIndex,
-		})
+// forgetIfGone drops the processed index of a process that is no longer
+// desired once its last actual LRP is gone as well, so that the indices of
+// removed processes do not pile up. A change that is yet to be processed
+// keeps the index around.
+func (h Handler) forgetIfGone(logger lager.Logger, processGuid string, pending *pendingChanges) {
+	if _, ok := h.registry.ProcessedIndex(processGuid); !ok {
+		return
+	}
 
-		instanceGuid, err := uuid.NewV4()
-		if err != nil {
-			changeLogger.Error("generating-instance-guid-failed", err)
-			return
-		}
+	actualLRPs, err := h.bbs.GetActualLRPsByProcessGuid(processGuid)
+	if err != nil {
+		logger.Error("fetch-actuals-failed", err, lager.Data{"process-guid": processGuid})
+		return
+	}
 
-		preprocessedLRP, err := h.lrPreProcessor.PreProcess(desiredLRP, lrpIndex, instanceGuid.String())
-		if err != nil {
-			changeLogger.Error("failed-to-preprocess-lrp", err)
-			return
-		}
+	if len(actualLRPs) > 0 || pending.waiting(processGuid) {
+		return
+	}
 
-		startMessage := models.LRPStartAuction{
-			DesiredLRP: preprocessedLRP,
+	h.registry.ForgetProcessedIndex(processGuid)
+}
+
+// Instances that go away or move need replacing, and instances that start
+// running may let a rolling update make progress.
+func requiresReconcile(actualChange models.ActualLRPChange) bool {
+	if actualChange.After == nil {
+		return actualChange.Before != nil
+	}
 
-			Index:        lrpIndex,
-			InstanceGuid: instanceGuid.String(),
+	if actualChange.After.State == models.ActualLRPStateRunning {
+		if actualChange.Before == nil || actualChange.Before.State != models.ActualLRPStateRunning {
+			return true
 		}
+	}
 
-		err = h.bbs.RequestLRPStartAuction(startMessage)
+	if actualChange.Before == nil {
+		return false
+	}
 
-		if err != nil {
-			changeLogger.Error("request-start-auction-failed", err, lager.Data{
-				"desired-app-message": desiredLRP,
-				"index":               lrpIndex,
-			})
+	return actualChange.Before.Index != actualChange.After.Index
+}
 
-		}
+func processGuidOf(actualChange models.ActualLRPChange) string {
+	if actualChange.After != nil {
+		return actualChange.After.ProcessGuid
+	}
+
+	return actualChange.Before.ProcessGuid
+}
+
+func desiredProcessGuidOf(desiredChange models.DesiredLRPChange) string {
+	if desiredChange.After != nil {
+		return desiredChange.After.ProcessGuid
 	}
 
-	for _, guidToStop := range delta.GuidsToStop {
-		changeLogger.Info("request-stop-instance", lager.Data{
-			"desired-app-message": desiredLRP,
-			"stop-instance-guid":  guidToStop,
-		})
+	return desiredChange.Before.ProcessGuid
+}
 
-		actualToStop := instanceGuidToActual[guidToStop]
+// pendingChanges coalesces the desired changes for a process guid that have
+// not been processed yet into a single change from the first Before to the
+// newest After, at the index of the newest change. It also keeps the index of
+// the change that is being processed, until it is done.
+type pendingChanges struct {
+	changes    map[string]desiredwatch.DesiredLRPChange
+	processing map[string]uint64
+	lock       sync.Mutex
+}
 
-		err = h.bbs.RequestStopLRPInstance(models.StopLRPInstance{
-			ProcessGuid:  actualToStop.ProcessGuid,
-			InstanceGuid: actualToStop.InstanceGuid,
-			Index:        actualToStop.Index,
-		})
+// add keeps the change with the higher index, so that a change that arrives
+// out of order does not replace a newer one. Removing the version that is
+// pending is newer than that version. A pending creation has no Before to
+// carry over, so removing what it created stays a removal.
+func (p *pendingChanges) add(processGuid string, desiredChange desiredwatch.DesiredLRPChange) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 
-		if err != nil {
-			changeLogger.Error("request-stop-instance-failed", err, lager.Data{
-				"desired-app-message": desiredLRP,
-				"stop-instance-guid":  guidToStop,
-			})
+	if existing, ok := p.changes[processGuid]; ok {
+		if existing.Index > desiredChange.Index || existing.Index == desiredChange.Index && !desiredChange.Removed() {
+			return
 		}
-	}
-
-	for _, indexToStopAllButOne := range delta.IndicesToStopAllButOne {
-		changeLogger.Info("request-stop-auction", lager.Data{
-			"desired-app-message":  desiredLRP,
-			"stop-duplicate-index": indexToStopAllButOne,
-		})
-		err = h.bbs.RequestLRPStopAuction(models.LRPStopAuction{
-			ProcessGuid: desiredLRP.ProcessGuid,
-			Index:       indexToStopAllButOne,
-		})
 
-		if err != nil {
-			changeLogger.Error("request-stop-auction-failed", err, lager.Data{
-				"desired-app-message":  desiredLRP,
-				"stop-duplicate-index": indexToStopAllButOne,
-			})
+		if existing.Before != nil {
+			desiredChange.Before = existing.Before
 		}
 	}
+
+	p.changes[processGuid] = desiredChange
 }
 
-func (h Handler) actualsForProcessGuid(lrpGuid string) (delta_force.ActualInstances, map[string]models.ActualLRP, error) {
-	actualInstances := delta_force.ActualInstances{}
-	actualLRPs, err := h.bbs.GetActualLRPsByProcessGuid(lrpGuid)
-	instanceGuidToActual := map[string]models.ActualLRP{}
+func (p *pendingChanges) take(processGuid string) (desiredwatch.DesiredLRPChange, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 
-	if err != nil {
-		return actualInstances, instanceGuidToActual, err
+	desiredChange, ok := p.changes[processGuid]
+	if ok {
+		delete(p.changes, processGuid)
+		p.processing[processGuid] = desiredChange.Index
 	}
 
-	for _, actualLRP := range actualLRPs {
-		actualInstances = append(actualInstances, delta_force.ActualInstance{actualLRP.Index, actualLRP.InstanceGuid})
-		instanceGuidToActual[actualLRP.InstanceGuid] = actualLRP
+	return desiredChange, ok
+}
+
+func (p *pendingChanges) done(processGuid string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.processing, processGuid)
+}
+
+// newest returns the highest index of the changes for the process guid that
+// are waiting or being processed.
+func (p *pendingChanges) newest(processGuid string) (uint64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	desiredChange, waiting := p.changes[processGuid]
+	processingIndex, processing := p.processing[processGuid]
+
+	if waiting && (!processing || desiredChange.Index > processingIndex) {
+		return desiredChange.Index, true
 	}
 
-	return actualInstances, instanceGuidToActual, err
+	return processingIndex, processing
+}
+
+func (p *pendingChanges) waiting(processGuid string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, ok := p.changes[processGuid]
+	return ok
 }
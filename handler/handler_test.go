@@ -3,11 +3,17 @@ package handler_test
 import (
 	"errors"
 	"syscall"
+	"time"
 
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
 	. "github.com/cloudfoundry-incubator/app-manager/handler"
 	"github.com/cloudfoundry-incubator/app-manager/handler/fakes"
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fake_bbs"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	metrics_fakes "github.com/cloudfoundry-incubator/app-manager/metrics/fakes"
+	processor_fakes "github.com/cloudfoundry-incubator/app-manager/processor/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/status"
 	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
 	"github.com/pivotal-golang/lager/lagertest"
 	"github.com/tedsuo/ifrit"
 
@@ -18,30 +24,41 @@ import (
 
 var _ = Describe("Handler", func() {
 	var (
-		bbs                       *fake_bbs.FakeAppManagerBBS
-		lrpp                      *fakes.FakeLRPreProcessor
-		logger                    *lagertest.TestLogger
-		desiredLRP                models.DesiredLRP
-		repAddrRelativeToExecutor string
-		healthChecks              map[string]string
+		bbs         *fakes.FakeBBS
+		processor   *processor_fakes.FakeProcessor
+		registry    status.Registry
+		emitter     *metrics_fakes.FakeEmitter
+		schedulable chan string
+		startable   chan string
+		logger      *lagertest.TestLogger
+		desiredLRP  models.DesiredLRP
 
 		handler ifrit.Process
 	)
 
-	BeforeEach(func() {
-		bbs = fake_bbs.NewFakeAppManagerBBS()
-
-		repAddrRelativeToExecutor = "127.0.0.1:20515"
-
-		healthChecks = map[string]string{
-			"some-stack": "some-health-check.tgz",
+	desiredChange := func(before, after *models.DesiredLRP, index uint64) desiredwatch.DesiredLRPChange {
+		return desiredwatch.DesiredLRPChange{
+			DesiredLRPChange: models.DesiredLRPChange{
+				Before: before,
+				After:  after,
+			},
+			Index: index,
 		}
+	}
+
+	BeforeEach(func() {
+		bbs = fakes.NewFakeBBS()
 
 		logger = lagertest.NewTestLogger("test")
 
-		lrpp = new(fakes.FakeLRPreProcessor)
+		processor = new(processor_fakes.FakeProcessor)
 
-		handlerRunner := NewHandler(bbs, lrpp, logger)
+		registry = status.NewRegistry()
+		emitter = metrics_fakes.NewFakeEmitter()
+		schedulable = make(chan string)
+		startable = make(chan string)
+
+		handlerRunner := NewHandler(bbs, bbs, processor, registry, emitter, schedulable, startable, 2, 100*time.Millisecond, logger)
 
 		desiredLRP = models.DesiredLRP{
 			ProcessGuid: "the-app-guid-the-app-version",
@@ -65,386 +82,562 @@ var _ = Describe("Handler", func() {
 		handler.Signal(syscall.SIGINT)
 		<-handler.Wait()
 		Eventually(bbs.DesiredLRPStopChan).Should(BeClosed())
+		Eventually(bbs.ActualLRPStopChan).Should(BeClosed())
 		close(done)
 	})
 
 	Describe("lifecycle", func() {
 		Describe("waiting until all desired are processed before shutting down", func() {
-			var receivedAuctions chan models.LRPStartAuction
+			var processedChanges chan models.DesiredLRPChange
 
 			BeforeEach(func() {
-				receivedAuctions = make(chan models.LRPStartAuction)
-				bbs.WhenRequestingLRPStartAuctions = func(lrp models.LRPStartAuction) error {
-					receivedAuctions <- lrp
+				processedChanges = make(chan models.DesiredLRPChange)
+				processor.ProcessDesiredChangeStub = func(change models.DesiredLRPChange) error {
+					processedChanges <- change
 					return nil
 				}
 			})
 
 			It("should not shut down until all desireds are processed", func() {
-				bbs.DesiredLRPChangeChan <- models.DesiredLRPChange{
-					Before: nil,
-					After:  &desiredLRP,
-				}
-				bbs.DesiredLRPChangeChan <- models.DesiredLRPChange{
-					Before: nil,
-					After:  &desiredLRP,
-				}
+				otherDesiredLRP := desiredLRP
+				otherDesiredLRP.ProcessGuid = "some-other-app-guid"
+
+				bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 1)
+				bbs.DesiredLRPChangeChan <- desiredChange(nil, &otherDesiredLRP, 2)
+
+				// make sure both changes have been received before shutting down
+				Eventually(func() int {
+					return len(bbs.DesiredLRPChangeChan)
+				}).Should(BeZero())
 
 				handler.Signal(syscall.SIGINT)
 				didShutDown := handler.Wait()
 
 				Consistently(didShutDown).ShouldNot(Receive())
 
-				for i := 0; i < desiredLRP.Instances*2; i++ {
-					Eventually(receivedAuctions).Should(Receive())
+				for i := 0; i < 2; i++ {
+					Eventually(processedChanges).Should(Receive())
 				}
 
 				Eventually(didShutDown).Should(Receive())
 			})
 		})
 
+		It("reports the desired LRP watch as established", func() {
+			Ω(registry.DesiredWatchEstablished()).Should(BeTrue())
+		})
+
 		Describe("when an error occurs", func() {
-			var newChan chan models.DesiredLRPChange
+			var newChan chan desiredwatch.DesiredLRPChange
 			BeforeEach(func() {
-				newChan = make(chan models.DesiredLRPChange, 1)
+				newChan = make(chan desiredwatch.DesiredLRPChange, 1)
 				bbs.DesiredLRPChangeChan = newChan
 				bbs.DesiredLRPErrChan <- errors.New("oops")
 			})
 
 			It("should reestablish the watch", func() {
-				newChan <- models.DesiredLRPChange{
-					Before: nil,
-					After:  &desiredLRP,
-				}
+				newChan <- desiredChange(nil, &desiredLRP, 1)
+
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			})
 
-				Eventually(bbs.GetLRPStartAuctions).Should(HaveLen(2))
+			It("counts the reconnect", func() {
+				Eventually(func() int {
+					return emitter.Counter(metrics.WatchReconnects)
+				}).Should(Equal(1))
 			})
 		})
 
 		Describe("when the desired channel is closed", func() {
-			var newChan chan models.DesiredLRPChange
+			var newChan chan desiredwatch.DesiredLRPChange
 			BeforeEach(func() {
-				newChan = make(chan models.DesiredLRPChange, 1)
+				newChan = make(chan desiredwatch.DesiredLRPChange, 1)
 				oldChan := bbs.DesiredLRPChangeChan
 				bbs.DesiredLRPChangeChan = newChan
 				close(oldChan)
 			})
 
 			It("should reestablish the watch", func() {
-				newChan <- models.DesiredLRPChange{
-					Before: nil,
-					After:  &desiredLRP,
-				}
+				newChan <- desiredChange(nil, &desiredLRP, 1)
 
-				Eventually(bbs.GetLRPStartAuctions).Should(HaveLen(2))
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
 			})
 		})
 
 	})
 
 	Describe("when a desired LRP change message is received", func() {
-		JustBeforeEach(func() {
-			bbs.DesiredLRPChangeChan <- models.DesiredLRPChange{
+		BeforeEach(func() {
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 5)
+		})
+
+		It("processes the change", func() {
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			Ω(processor.ProcessDesiredChangeArgsForCall(0)).Should(Equal(models.DesiredLRPChange{
 				Before: nil,
 				After:  &desiredLRP,
-			}
+			}))
+		})
+
+		It("records the index of the change as processed", func() {
+			Eventually(func() uint64 {
+				index, _ := registry.ProcessedIndex("the-app-guid-the-app-version")
+				return index
+			}).Should(Equal(uint64(5)))
 		})
 
-		Describe("the happy path", func() {
+		Context("and then an older change is received", func() {
 			BeforeEach(func() {
-				bbs.WhenGettingAvailableFileServer = func() (string, error) {
-					return "http://file-server.com/", nil
-				}
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeTrue())
 
-				lrpp.PreProcessStub = func(lrp models.DesiredLRP, index int, guid string) (models.DesiredLRP, error) {
-					lrp.ProcessGuid = "preprocessed-" + lrp.ProcessGuid
-					return lrp, nil
-				}
+				bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 3)
 			})
 
-			It("puts a LRPStartAuction in the bbs with a preprocessed LRP", func() {
-				Eventually(bbs.GetLRPStartAuctions).Should(HaveLen(2))
+			It("ignores it", func() {
+				Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			})
 
-				startAuctions := bbs.GetLRPStartAuctions()
+			It("logs and counts that it was stale", func() {
+				Eventually(logger.TestSink.Buffer).Should(gbytes.Say("handler.ignoring-stale-desired-change"))
+				Ω(emitter.Counter(metrics.StaleDesiredChanges)).Should(Equal(1))
+			})
+		})
 
-				firstStartAuction := startAuctions[0]
-				Ω(firstStartAuction.DesiredLRP.ProcessGuid).Should(Equal("preprocessed-the-app-guid-the-app-version"))
-				Ω(firstStartAuction.InstanceGuid).ShouldNot(BeEmpty())
+		Context("and then the same change is received again", func() {
+			BeforeEach(func() {
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeTrue())
 
-				secondStartAuction := startAuctions[1]
-				Ω(secondStartAuction.DesiredLRP.ProcessGuid).Should(Equal("preprocessed-the-app-guid-the-app-version"))
-				Ω(secondStartAuction.InstanceGuid).ShouldNot(BeEmpty())
+				bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 5)
+			})
 
-				Ω(firstStartAuction.InstanceGuid).ShouldNot(Equal(secondStartAuction.InstanceGuid))
+			It("ignores it", func() {
+				Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
 			})
+		})
 
-			It("assigns increasing indices for the auction requests", func() {
-				Eventually(bbs.GetLRPStartAuctions).Should(HaveLen(2))
-				startAuctions := bbs.GetLRPStartAuctions()
+		Context("and then the version that was processed is removed", func() {
+			BeforeEach(func() {
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeTrue())
 
-				firstStartAuction := startAuctions[0]
-				secondStartAuction := startAuctions[1]
+				bbs.DesiredLRPChangeChan <- desiredChange(&desiredLRP, nil, 5)
+			})
 
-				Ω(firstStartAuction.Index).Should(Equal(0))
-				Ω(secondStartAuction.Index).Should(Equal(1))
+			It("processes the removal", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+				Ω(processor.ProcessDesiredChangeArgsForCall(1)).Should(Equal(models.DesiredLRPChange{
+					Before: &desiredLRP,
+					After:  nil,
+				}))
+			})
+
+			It("forgets the processed index once no actual LRPs are left", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeFalse())
 			})
 		})
 
-		Context("when preprocessing fails", func() {
+		Context("and then the version that was processed is removed while actual LRPs are left", func() {
+			var actualLRP models.ActualLRP
+
 			BeforeEach(func() {
-				lrpp.PreProcessReturns(models.DesiredLRP{}, errors.New("oh no!"))
+				actualLRP = models.ActualLRP{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					InstanceGuid: "a",
+					Index:        0,
+					State:        models.ActualLRPStateRunning,
+				}
+				bbs.SetActualLRPs([]models.ActualLRP{actualLRP})
+
+				bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+					return models.DesiredLRP{}, storeadapter.ErrorKeyNotFound
+				}
+
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeTrue())
+
+				bbs.DesiredLRPChangeChan <- desiredChange(&desiredLRP, nil, 5)
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
 			})
 
-			It("does not put a LRPStartAuction in the bbs", func() {
-				Consistently(bbs.GetLRPStartAuctions).Should(BeEmpty())
+			It("keeps the processed index until the last of them is gone", func() {
+				Consistently(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeTrue())
+
+				bbs.SetActualLRPs(nil)
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{Before: &actualLRP}
+
+				Eventually(func() bool {
+					_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+					return ok
+				}).Should(BeFalse())
 			})
 		})
+	})
+
+	Describe("when processing a desired LRP change fails", func() {
+		BeforeEach(func() {
+			processor.ProcessDesiredChangeReturns(errors.New("oh no"))
+
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 5)
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+		})
+
+		It("logs the failure and does not record the index as processed", func() {
+			Eventually(logger.TestSink.Buffer).Should(gbytes.Say("handler.process-desired-change-failed"))
+
+			Consistently(func() bool {
+				_, ok := registry.ProcessedIndex("the-app-guid-the-app-version")
+				return ok
+			}).Should(BeFalse())
+		})
+
+		It("processes the same change again when it is received again", func() {
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 5)
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+		})
+	})
+
+	Describe("when several desired LRP changes for the same process guid are received", func() {
+		var release chan struct{}
+		var updatedLRP models.DesiredLRP
+		var rescaledLRP models.DesiredLRP
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			processor.ProcessDesiredChangeStub = func(models.DesiredLRPChange) error {
+				<-release
+				return nil
+			}
+
+			updatedLRP = desiredLRP
+			updatedLRP.Stack = "some-other-stack"
 
-		Context("when there is an error writing a LRPStartAuction to the BBS", func() {
+			rescaledLRP = updatedLRP
+			rescaledLRP.Instances = 5
+
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 1)
+
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+
+			bbs.DesiredLRPChangeChan <- desiredChange(&desiredLRP, &updatedLRP, 2)
+			bbs.DesiredLRPChangeChan <- desiredChange(&updatedLRP, &rescaledLRP, 3)
+		})
+
+		It("processes them one at a time", func() {
+			Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+
+			close(release)
+
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+		})
+
+		It("coalesces the changes that were waiting into one", func() {
+			close(release)
+
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+			Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+
+			Ω(processor.ProcessDesiredChangeArgsForCall(1)).Should(Equal(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  &rescaledLRP,
+			}))
+		})
+
+		Context("and an older change arrives after them", func() {
 			BeforeEach(func() {
-				bbs.LRPStartAuctionErr = errors.New("connection error")
+				bbs.DesiredLRPChangeChan <- desiredChange(&desiredLRP, &updatedLRP, 2)
 			})
 
-			It("logs an error", func() {
-				Eventually(logger.TestSink.Buffer).Should(gbytes.Say("handler.desired-lrp-change.request-start-auction-failed"))
+			It("ignores it in favour of the newest change that is waiting", func() {
+				Eventually(logger.TestSink.Buffer).Should(gbytes.Say("handler.ignoring-stale-desired-change"))
+
+				close(release)
+
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+				Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+
+				Ω(processor.ProcessDesiredChangeArgsForCall(1)).Should(Equal(models.DesiredLRPChange{
+					Before: &desiredLRP,
+					After:  &rescaledLRP,
+				}))
 			})
 		})
 
-		Context("when there is an error fetching the actual instances", func() {
+		Context("and a change older than the one being processed arrives", func() {
 			BeforeEach(func() {
-				bbs.ActualLRPsErr = errors.New("connection error")
+				bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 0)
 			})
 
-			It("does not put a LRPStartAuction in the bbs", func() {
-				Consistently(bbs.GetLRPStartAuctions).Should(BeEmpty())
+			It("ignores it", func() {
+				Eventually(func() int {
+					return emitter.Counter(metrics.StaleDesiredChanges)
+				}).Should(Equal(1))
+
+				close(release)
 			})
 		})
+	})
+
+	Describe("when a process is created and removed before its creation is processed", func() {
+		var release chan struct{}
+		var recreatedLRP models.DesiredLRP
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			processor.ProcessDesiredChangeStub = func(models.DesiredLRPChange) error {
+				<-release
+				return nil
+			}
+
+			recreatedLRP = desiredLRP
+			recreatedLRP.Instances = 3
+
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &desiredLRP, 1)
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+
+			bbs.DesiredLRPChangeChan <- desiredChange(nil, &recreatedLRP, 2)
+			bbs.DesiredLRPChangeChan <- desiredChange(&recreatedLRP, nil, 2)
+		})
+
+		It("processes the removal of what was created", func() {
+			close(release)
+
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+			Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(2))
+
+			Ω(processor.ProcessDesiredChangeArgsForCall(1)).Should(Equal(models.DesiredLRPChange{
+				Before: &recreatedLRP,
+				After:  nil,
+			}))
+		})
+	})
+
+	Describe("when an actual LRP change message is received", func() {
+		var actualLRP models.ActualLRP
+
+		BeforeEach(func() {
+			actualLRP = models.ActualLRP{
+				ProcessGuid:  "the-app-guid-the-app-version",
+				InstanceGuid: "a",
+				Index:        0,
+				State:        models.ActualLRPStateRunning,
+			}
+
+			bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+				return desiredLRP, nil
+			}
+		})
 
-		Context("when there are already instances running for the desired app, but some are missing", func() {
+		Context("when the actual LRP is removed", func() {
 			BeforeEach(func() {
-				desiredLRP.Instances = 4
-				bbs.Lock()
-				bbs.ActualLRPs = []models.ActualLRP{
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "a",
-						Index:        0,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "b",
-						Index:        4,
-						State:        models.ActualLRPStateRunning,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "c",
-						Index:        5,
-						State:        models.ActualLRPStateRunning,
-					},
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: &actualLRP,
+					After:  nil,
 				}
-				bbs.Unlock()
 			})
 
-			It("only starts missing ones", func() {
-				Eventually(bbs.GetLRPStartAuctions).Should(HaveLen(3))
-				startAuctions := bbs.GetLRPStartAuctions()
+			It("reconciles the current desired LRP for the process guid", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+				Ω(bbs.GetDesiredLRPProcessGuids()).Should(Equal([]string{"the-app-guid-the-app-version"}))
+				Ω(processor.ProcessDesiredChangeArgsForCall(0)).Should(Equal(models.DesiredLRPChange{
+					Before: &desiredLRP,
+					After:  &desiredLRP,
+				}))
+			})
 
-				Ω(startAuctions[0].Index).Should(Equal(1))
-				Ω(startAuctions[1].Index).Should(Equal(2))
-				Ω(startAuctions[2].Index).Should(Equal(3))
+			Context("when more instances of the same process are removed in quick succession", func() {
+				BeforeEach(func() {
+					otherActualLRP := actualLRP
+					otherActualLRP.InstanceGuid = "b"
+					otherActualLRP.Index = 1
+
+					bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+						Before: &otherActualLRP,
+						After:  nil,
+					}
+				})
+
+				It("reconciles only once", func() {
+					Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+					Consistently(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+				})
 			})
 
-			It("does not stop extra ones", func() {
-				Consistently(bbs.GetStopLRPInstances).Should(BeEmpty())
+			Context("when the desired LRP no longer exists", func() {
+				BeforeEach(func() {
+					bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+						return models.DesiredLRP{}, storeadapter.ErrorKeyNotFound
+					}
+				})
+
+				It("does not reconcile", func() {
+					Eventually(bbs.GetDesiredLRPProcessGuids).Should(HaveLen(1))
+					Consistently(processor.ProcessDesiredChangeCallCount).Should(BeZero())
+				})
+			})
+
+			Context("when fetching the desired LRP fails", func() {
+				BeforeEach(func() {
+					bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+						return models.DesiredLRP{}, errors.New("oh no")
+					}
+				})
+
+				It("logs and does not reconcile", func() {
+					Eventually(logger.TestSink.Buffer).Should(gbytes.Say("handler.actual-lrp-change.fetch-desired-failed"))
+					Ω(processor.ProcessDesiredChangeCallCount()).Should(BeZero())
+				})
 			})
 		})
 
-		Context("when there are extra instances running for the desired app", func() {
+		Context("when the actual LRP changes index", func() {
 			BeforeEach(func() {
-				desiredLRP.Instances = 2
-				bbs.Lock()
-				bbs.ActualLRPs = []models.ActualLRP{
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "a",
-						Index:        0,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "b",
-						Index:        1,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "c",
-						Index:        2,
-						State:        models.ActualLRPStateRunning,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "d",
-						Index:        3,
-						State:        models.ActualLRPStateRunning,
-					},
+				movedActualLRP := actualLRP
+				movedActualLRP.Index = 3
+
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: &actualLRP,
+					After:  &movedActualLRP,
 				}
-				bbs.Unlock()
 			})
 
-			It("doesn't start anything", func() {
-				Consistently(bbs.GetLRPStartAuctions).Should(BeEmpty())
+			It("reconciles the process guid", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
 			})
+		})
 
-			It("stops extra ones", func() {
-				Eventually(bbs.GetStopLRPInstances).Should(HaveLen(2))
-				stopInstances := bbs.GetStopLRPInstances()
+		Context("when the actual LRP starts running", func() {
+			BeforeEach(func() {
+				startingActualLRP := actualLRP
+				startingActualLRP.State = models.ActualLRPStateStarting
 
-				stopInstance1 := models.StopLRPInstance{
-					ProcessGuid:  "the-app-guid-the-app-version",
-					Index:        2,
-					InstanceGuid: "c",
-				}
-				stopInstance2 := models.StopLRPInstance{
-					ProcessGuid:  "the-app-guid-the-app-version",
-					Index:        3,
-					InstanceGuid: "d",
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: &startingActualLRP,
+					After:  &actualLRP,
 				}
+			})
 
-				Ω(stopInstances).Should(ContainElement(stopInstance1))
-				Ω(stopInstances).Should(ContainElement(stopInstance2))
+			It("reconciles the process guid", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
 			})
 		})
 
-		Context("when there are duplicate desired instances running for the desired app", func() {
+		Context("when the actual LRP changes without moving or starting to run", func() {
 			BeforeEach(func() {
-				desiredLRP.Instances = 3
-				bbs.Lock()
-				bbs.ActualLRPs = []models.ActualLRP{
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "a",
-						Index:        0,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "b",
-						Index:        1,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "c",
-						Index:        1,
-						State:        models.ActualLRPStateStarting,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "d",
-						Index:        2,
-						State:        models.ActualLRPStateRunning,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "e",
-						Index:        2,
-						State:        models.ActualLRPStateRunning,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "f",
-						Index:        3,
-						State:        models.ActualLRPStateRunning,
-					},
-					{
-						ProcessGuid:  "the-app-guid-the-app-version",
-						InstanceGuid: "g",
-						Index:        3,
-						State:        models.ActualLRPStateRunning,
-					},
+				movedActualLRP := actualLRP
+				movedActualLRP.Host = "some-other-host"
+
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: &actualLRP,
+					After:  &movedActualLRP,
 				}
-				bbs.Unlock()
 			})
 
-			It("doesn't start anything", func() {
-				Consistently(bbs.GetLRPStartAuctions).Should(BeEmpty())
+			It("does not reconcile", func() {
+				Consistently(processor.ProcessDesiredChangeCallCount).Should(BeZero())
 			})
+		})
 
-			It("holds stop auctions for the desired duplicates", func() {
-				Eventually(bbs.GetLRPStopAuctions).Should(HaveLen(2))
-				stopAuctions := bbs.GetLRPStopAuctions()
-
-				Ω(stopAuctions).Should(ContainElement(models.LRPStopAuction{
-					ProcessGuid: "the-app-guid-the-app-version",
-					Index:       1,
-				}))
+		Context("when the actual LRP is created starting", func() {
+			BeforeEach(func() {
+				startingActualLRP := actualLRP
+				startingActualLRP.State = models.ActualLRPStateStarting
 
-				Ω(stopAuctions).Should(ContainElement(models.LRPStopAuction{
-					ProcessGuid: "the-app-guid-the-app-version",
-					Index:       2,
-				}))
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: nil,
+					After:  &startingActualLRP,
+				}
 			})
 
-			It("stops extra ones", func() {
-				Eventually(bbs.GetStopLRPInstances).Should(HaveLen(2))
-				stopInstances := bbs.GetStopLRPInstances()
+			It("does not reconcile", func() {
+				Consistently(processor.ProcessDesiredChangeCallCount).Should(BeZero())
+			})
+		})
 
-				stopInstance1 := models.StopLRPInstance{
-					ProcessGuid:  "the-app-guid-the-app-version",
-					Index:        3,
-					InstanceGuid: "f",
-				}
-				stopInstance2 := models.StopLRPInstance{
-					ProcessGuid:  "the-app-guid-the-app-version",
-					Index:        3,
-					InstanceGuid: "g",
+		Context("when the actual LRP is created running", func() {
+			BeforeEach(func() {
+				bbs.ActualLRPChangeChan <- models.ActualLRPChange{
+					Before: nil,
+					After:  &actualLRP,
 				}
+			})
 
-				Ω(stopInstances).Should(ContainElement(stopInstance1))
-				Ω(stopInstances).Should(ContainElement(stopInstance2))
+			It("reconciles the process guid", func() {
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
 			})
 		})
-	})
 
-	Describe("when a desired LRP is deleted", func() {
-		JustBeforeEach(func() {
-			bbs.DesiredLRPChangeChan <- models.DesiredLRPChange{
-				Before: &desiredLRP,
-				After:  nil,
-			}
+		Context("when the actual watch errors", func() {
+			var newChan chan models.ActualLRPChange
+
+			BeforeEach(func() {
+				newChan = make(chan models.ActualLRPChange, 1)
+				bbs.ActualLRPChangeChan = newChan
+				bbs.ActualLRPErrChan <- errors.New("oops")
+			})
+
+			It("should reestablish the watch", func() {
+				newChan <- models.ActualLRPChange{
+					Before: &actualLRP,
+					After:  nil,
+				}
+
+				Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			})
 		})
+	})
 
+	Describe("when a held process becomes schedulable", func() {
 		BeforeEach(func() {
-			bbs.Lock()
-			bbs.ActualLRPs = []models.ActualLRP{
-				{
-					ProcessGuid:  "the-app-guid-the-app-version",
-					InstanceGuid: "a",
-					Index:        0,
-					State:        models.ActualLRPStateStarting,
-				},
+			bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+				return desiredLRP, nil
 			}
-			bbs.Unlock()
-		})
 
-		It("doesn't start anything", func() {
-			Consistently(bbs.GetLRPStartAuctions).Should(BeEmpty())
+			schedulable <- "the-app-guid-the-app-version"
 		})
 
-		It("stops all instances", func() {
-			Eventually(bbs.GetStopLRPInstances).Should(HaveLen(1))
-			stopInstances := bbs.GetStopLRPInstances()
+		It("reconciles the current desired LRP for the process guid", func() {
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			Ω(bbs.GetDesiredLRPProcessGuids()).Should(Equal([]string{"the-app-guid-the-app-version"}))
+			Ω(processor.ProcessDesiredChangeArgsForCall(0)).Should(Equal(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  &desiredLRP,
+			}))
+		})
+	})
 
-			stopInstance := models.StopLRPInstance{
-				ProcessGuid:  "the-app-guid-the-app-version",
-				Index:        0,
-				InstanceGuid: "a",
+	Describe("when a throttled process may start again", func() {
+		BeforeEach(func() {
+			bbs.WhenGettingDesiredLRPByProcessGuid = func(processGuid string) (models.DesiredLRP, error) {
+				return desiredLRP, nil
 			}
 
-			Ω(stopInstances).Should(ContainElement(stopInstance))
+			startable <- "the-app-guid-the-app-version"
+		})
+
+		It("reconciles the current desired LRP for the process guid", func() {
+			Eventually(processor.ProcessDesiredChangeCallCount).Should(Equal(1))
+			Ω(processor.ProcessDesiredChangeArgsForCall(0)).Should(Equal(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  &desiredLRP,
+			}))
 		})
 	})
 })
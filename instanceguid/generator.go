@@ -0,0 +1,51 @@
+package instanceguid
+
+import (
+	"fmt"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+// A Generator comes up with the guid of an instance to start at an index of
+// a process. The desired index is the modified index of the desired LRP the
+// instance is started for, which outlives the app manager; the generation
+// tells apart instances started at the same index of it one after the other.
+type Generator interface {
+	InstanceGuid(processGuid string, index int, desiredIndex uint64, generation int) (string, error)
+}
+
+var namespace, _ = uuid.ParseHex("2d51abb2-01ab-4d0f-aaaa-34c2ab761e4f")
+
+type random struct{}
+
+// NewRandom returns a generator that comes up with a new guid every time.
+func NewRandom() Generator {
+	return random{}
+}
+
+func (random) InstanceGuid(processGuid string, index int, desiredIndex uint64, generation int) (string, error) {
+	instanceGuid, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	return instanceGuid.String(), nil
+}
+
+type deterministic struct{}
+
+// NewDeterministic returns a generator that derives the guid from the
+// process guid, index, desired index and generation, so that starting the
+// same generation of an index twice requests the very same instance.
+func NewDeterministic() Generator {
+	return deterministic{}
+}
+
+func (deterministic) InstanceGuid(processGuid string, index int, desiredIndex uint64, generation int) (string, error) {
+	instanceGuid, err := uuid.NewV5(namespace, []byte(fmt.Sprintf("%s/%d/%d/%d", processGuid, index, desiredIndex, generation)))
+	if err != nil {
+		return "", err
+	}
+
+	return instanceGuid.String(), nil
+}
@@ -0,0 +1,51 @@
+package instanceguid_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/instanceguid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generator", func() {
+	instanceGuid := func(generator Generator, processGuid string, index int, desiredIndex uint64, generation int) string {
+		guid, err := generator.InstanceGuid(processGuid, index, desiredIndex, generation)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(guid).ShouldNot(BeEmpty())
+		return guid
+	}
+
+	Describe("a random generator", func() {
+		It("comes up with a new guid every time", func() {
+			generator := NewRandom()
+
+			Ω(instanceGuid(generator, "some-process-guid", 0, 7, 0)).ShouldNot(Equal(instanceGuid(generator, "some-process-guid", 0, 7, 0)))
+		})
+	})
+
+	Describe("a deterministic generator", func() {
+		var generator Generator
+
+		BeforeEach(func() {
+			generator = NewDeterministic()
+		})
+
+		It("comes up with the same guid for the same generation of an index of a desired LRP", func() {
+			Ω(instanceGuid(generator, "some-process-guid", 0, 7, 0)).Should(Equal(instanceGuid(NewDeterministic(), "some-process-guid", 0, 7, 0)))
+		})
+
+		It("comes up with different guids for different processes, indices, desired LRPs and generations", func() {
+			guid := instanceGuid(generator, "some-process-guid", 0, 7, 0)
+
+			Ω(instanceGuid(generator, "some-other-process-guid", 0, 7, 0)).ShouldNot(Equal(guid))
+			Ω(instanceGuid(generator, "some-process-guid", 1, 7, 0)).ShouldNot(Equal(guid))
+			Ω(instanceGuid(generator, "some-process-guid", 0, 8, 0)).ShouldNot(Equal(guid))
+			Ω(instanceGuid(generator, "some-process-guid", 0, 7, 1)).ShouldNot(Equal(guid))
+		})
+
+		It("does not mix up indices, desired LRPs and generations", func() {
+			Ω(instanceGuid(generator, "some-process-guid", 1, 7, 12)).ShouldNot(Equal(instanceGuid(generator, "some-process-guid", 11, 7, 2)))
+			Ω(instanceGuid(generator, "some-process-guid", 1, 71, 2)).ShouldNot(Equal(instanceGuid(generator, "some-process-guid", 17, 1, 2)))
+		})
+	})
+})
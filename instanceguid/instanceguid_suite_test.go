@@ -0,0 +1,13 @@
+package instanceguid_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestInstanceGuid(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "InstanceGuid Suite")
+}
@@ -1,6 +1,7 @@
 package app_manager_runner
 
 import (
+	"encoding/json"
 	"os/exec"
 	"strings"
 	"time"
@@ -14,27 +15,37 @@ import (
 type AppManagerRunner struct {
 	appManagerBin string
 	etcdCluster   []string
+	healthChecks  map[string]string
+	listenAddr    string
 	Session       *gexec.Session
 }
 
-func New(appManagerBin string, etcdCluster []string) *AppManagerRunner {
+func New(appManagerBin string, etcdCluster []string, healthChecks map[string]string, listenAddr string) *AppManagerRunner {
 	return &AppManagerRunner{
 		appManagerBin: appManagerBin,
 		etcdCluster:   etcdCluster,
+		healthChecks:  healthChecks,
+		listenAddr:    listenAddr,
 	}
 }
 
-func (r *AppManagerRunner) Start() {
-	r.StartWithoutCheck()
+func (r *AppManagerRunner) Start(extraArgs ...string) {
+	r.StartWithoutCheck(extraArgs...)
 	Eventually(r.Session, 5*time.Second).Should(gbytes.Say("app-manager.started"))
 }
 
-func (r *AppManagerRunner) StartWithoutCheck() {
+func (r *AppManagerRunner) StartWithoutCheck(extraArgs ...string) {
+	healthChecksJSON, err := json.Marshal(r.healthChecks)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	args := append([]string{
+		"-etcdCluster", strings.Join(r.etcdCluster, ","),
+		"-healthChecks", string(healthChecksJSON),
+		"-listenAddr", r.listenAddr,
+	}, extraArgs...)
+
 	executorSession, err := gexec.Start(
-		exec.Command(
-			r.appManagerBin,
-			"-etcdCluster", strings.Join(r.etcdCluster, ","),
-		),
+		exec.Command(r.appManagerBin, args...),
 		gexec.NewPrefixedWriter("\x1b[32m[o]\x1b[35m[app-manager]\x1b[0m ", ginkgo.GinkgoWriter),
 		gexec.NewPrefixedWriter("\x1b[91m[e]\x1b[35m[app-manager]\x1b[0m ", ginkgo.GinkgoWriter),
 	)
@@ -0,0 +1,70 @@
+package integration_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry/storeadapter/test_helpers"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/app-manager/integration/app_manager_runner"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Running in dry-run mode", func() {
+	var bbs *Bbs.BBS
+
+	BeforeEach(func() {
+		bbs = Bbs.NewBBS(etcdRunner.Adapter(), timeprovider.NewTimeProvider(), lagertest.NewTestLogger("test"))
+
+		var err error
+		var presenceStatus <-chan bool
+
+		fileServerPresence, presenceStatus, err = bbs.MaintainFileServerPresence(time.Second, "http://some.file.server", "file-server-id")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Eventually(presenceStatus).Should(Receive(BeTrue()))
+
+		test_helpers.NewStatusReporter(presenceStatus)
+
+		runner = app_manager_runner.New(appManagerPath, etcdRunner.NodeURLS(), map[string]string{
+			"some-stack": "some-health-check.tgz",
+		}, listenAddr)
+
+		runner.Start("-dryRun")
+	})
+
+	AfterEach(func() {
+		runner.KillWithFire()
+		fileServerPresence.Remove()
+	})
+
+	It("does not take the lock", func() {
+		Consistently(runner.Session).ShouldNot(gbytes.Say("app-manager.lock"))
+	})
+
+	Context("when an LRP is desired", func() {
+		BeforeEach(func() {
+			err := bbs.DesireLRP(models.DesiredLRP{
+				ProcessGuid: "the-guid",
+				Stack:       "some-stack",
+				Instances:   3,
+				MemoryMB:    128,
+				Actions: []models.ExecutorAction{
+					{Action: models.RunAction{Path: "the-start-command"}},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("logs the starts it would request without requesting them", func() {
+			Eventually(runner.Session).Should(gbytes.Say("app-manager.dry-run.would-start"))
+			Consistently(bbs.GetAllLRPStartAuctions).Should(BeEmpty())
+		})
+	})
+})
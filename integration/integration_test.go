@@ -1,18 +1,23 @@
 package integration_test
 
 import (
+	"encoding/json"
+	"net/http"
 	"time"
 
 	"github.com/cloudfoundry/storeadapter/test_helpers"
 	"github.com/pivotal-golang/lager/lagertest"
 
+	"github.com/cloudfoundry-incubator/app-manager/freshness"
 	"github.com/cloudfoundry-incubator/app-manager/integration/app_manager_runner"
+	"github.com/cloudfoundry-incubator/app-manager/status"
 	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
 	"github.com/cloudfoundry-incubator/runtime-schema/models"
 	"github.com/cloudfoundry/gunk/timeprovider"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Starting apps", func() {
@@ -33,9 +38,11 @@ var _ = Describe("Starting apps", func() {
 
 		test_helpers.NewStatusReporter(presenceStatus)
 
-		runner = app_manager_runner.New(appManagerPath, etcdRunner.NodeURLS())
+		runner = app_manager_runner.New(appManagerPath, etcdRunner.NodeURLS(), map[string]string{
+			"some-stack": "some-health-check.tgz",
+		}, listenAddr)
 
-		runner.Start()
+		runner.Start("-requireFreshDomains")
 	})
 
 	AfterEach(func() {
@@ -43,10 +50,54 @@ var _ = Describe("Starting apps", func() {
 		fileServerPresence.Remove()
 	})
 
+	Describe("the status API", func() {
+		It("reports the desired LRP watch as healthy", func() {
+			Eventually(func() int {
+				resp, err := http.Get("http://" + listenAddr + status.HealthPath)
+				if err != nil {
+					return 0
+				}
+				resp.Body.Close()
+
+				return resp.StatusCode
+			}).Should(Equal(http.StatusOK))
+		})
+
+		It("reports on processes it has reconciled", func() {
+			err := bbs.DesireLRP(models.DesiredLRP{
+				ProcessGuid: "the-guid",
+				Stack:       "some-stack",
+				Instances:   2,
+				MemoryMB:    128,
+				Actions: []models.ExecutorAction{
+					{Action: models.RunAction{Path: "the-start-command"}},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(func() int {
+				resp, err := http.Get("http://" + listenAddr + status.ProcessesPath + "the-guid")
+				if err != nil {
+					return 0
+				}
+				defer resp.Body.Close()
+
+				var processStatus status.ProcessStatus
+				err = json.NewDecoder(resp.Body).Decode(&processStatus)
+				if err != nil {
+					return 0
+				}
+
+				return len(processStatus.StartAuctions)
+			}).Should(Equal(2))
+		})
+	})
+
 	Describe("when an LRP is desired", func() {
 		JustBeforeEach(func() {
 			err := bbs.DesireLRP(models.DesiredLRP{
 				ProcessGuid: "the-guid",
+				Domain:      "some-domain",
 
 				Stack: "some-stack",
 
@@ -136,6 +187,47 @@ var _ = Describe("Starting apps", func() {
 			})
 		})
 
+		Context("when a running instance disappears", func() {
+			var lostInstance models.ActualLRP
+
+			BeforeEach(func() {
+				lostInstance = models.ActualLRP{
+					ProcessGuid:  "the-guid",
+					InstanceGuid: "b",
+					Index:        1,
+				}
+
+				bbs.ReportActualLRPAsRunning(models.ActualLRP{
+					ProcessGuid:  "the-guid",
+					InstanceGuid: "a",
+					Index:        0,
+				}, "executor-id")
+
+				bbs.ReportActualLRPAsRunning(lostInstance, "executor-id")
+
+				bbs.ReportActualLRPAsRunning(models.ActualLRP{
+					ProcessGuid:  "the-guid",
+					InstanceGuid: "c",
+					Index:        2,
+				}, "executor-id")
+			})
+
+			JustBeforeEach(func() {
+				Consistently(bbs.GetAllLRPStartAuctions, 0.5).Should(BeEmpty())
+
+				err := bbs.RemoveActualLRP(lostInstance)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("starts an auction for the missing index", func() {
+				Eventually(bbs.GetAllLRPStartAuctions, 2).Should(HaveLen(1))
+				auctions, err := bbs.GetAllLRPStartAuctions()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(auctions[0].Index).Should(Equal(1))
+			})
+		})
+
 		Context("when an app is no longer desired", func() {
 			JustBeforeEach(func() {
 				Eventually(bbs.GetAllDesiredLRPs).Should(HaveLen(1))
@@ -146,6 +238,38 @@ var _ = Describe("Starting apps", func() {
 			It("should remove the desired state from etcd", func() {
 				Eventually(bbs.GetAllDesiredLRPs).Should(HaveLen(0))
 			})
+
+			Context("and it has running instances", func() {
+				BeforeEach(func() {
+					bbs.ReportActualLRPAsRunning(models.ActualLRP{
+						ProcessGuid:  "the-guid",
+						InstanceGuid: "a",
+						Index:        0,
+					}, "executor-id")
+				})
+
+				Context("when its domain is fresh", func() {
+					BeforeEach(func() {
+						err := freshness.NewFreshnessBBS(etcdRunner.Adapter()).BumpFreshness("some-domain", time.Minute)
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+
+					It("stops the instances", func() {
+						Eventually(bbs.GetAllStopLRPInstances).Should(HaveLen(1))
+						stopInstances, err := bbs.GetAllStopLRPInstances()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(stopInstances[0].InstanceGuid).Should(Equal("a"))
+					})
+				})
+
+				Context("when its domain is not fresh", func() {
+					It("leaves the instances running", func() {
+						Eventually(runner.Session).Should(gbytes.Say("skipping-stop-stale-domain"))
+						Consistently(bbs.GetAllStopLRPInstances).Should(BeEmpty())
+					})
+				})
+			})
 		})
 	})
 })
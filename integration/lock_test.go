@@ -0,0 +1,120 @@
+package integration_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry/storeadapter/test_helpers"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/app-manager/integration/app_manager_runner"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Running multiple app managers", func() {
+	var (
+		bbs         *Bbs.BBS
+		otherRunner *app_manager_runner.AppManagerRunner
+		desiredLRP  models.DesiredLRP
+	)
+
+	BeforeEach(func() {
+		bbs = Bbs.NewBBS(etcdRunner.Adapter(), timeprovider.NewTimeProvider(), lagertest.NewTestLogger("test"))
+
+		desiredLRP = models.DesiredLRP{
+			ProcessGuid: "the-guid",
+
+			Stack: "some-stack",
+
+			Instances: 3,
+			MemoryMB:  128,
+			DiskMB:    512,
+
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.RunAction{
+						Path: "the-start-command",
+					},
+				},
+			},
+		}
+
+		var err error
+		var presenceStatus <-chan bool
+
+		fileServerPresence, presenceStatus, err = bbs.MaintainFileServerPresence(time.Second, "http://some.file.server", "file-server-id")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Eventually(presenceStatus).Should(Receive(BeTrue()))
+
+		test_helpers.NewStatusReporter(presenceStatus)
+
+		healthChecks := map[string]string{
+			"some-stack": "some-health-check.tgz",
+		}
+
+		runner = app_manager_runner.New(appManagerPath, etcdRunner.NodeURLS(), healthChecks, listenAddr)
+		otherRunner = app_manager_runner.New(
+			appManagerPath,
+			etcdRunner.NodeURLS(),
+			healthChecks,
+			fmt.Sprintf("127.0.0.1:%d", 6101+GinkgoParallelNode()),
+		)
+
+		runner.Start()
+		Eventually(runner.Session).Should(gbytes.Say("app-manager.lock.acquired-lock"))
+
+		otherRunner.Start()
+	})
+
+	AfterEach(func() {
+		runner.KillWithFire()
+		otherRunner.KillWithFire()
+		fileServerPresence.Remove()
+	})
+
+	It("only lets one of them act", func() {
+		Consistently(otherRunner.Session, 2).ShouldNot(gbytes.Say("app-manager.lock.acquired-lock"))
+	})
+
+	It("requests one start auction per index", func() {
+		err := bbs.DesireLRP(desiredLRP)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		startedIndices := func() []int {
+			startAuctions, err := bbs.GetAllLRPStartAuctions()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			indices := []int{}
+			for _, startAuction := range startAuctions {
+				indices = append(indices, startAuction.Index)
+			}
+
+			return indices
+		}
+
+		Eventually(startedIndices).Should(HaveLen(3))
+		Consistently(startedIndices, 2).Should(ConsistOf(0, 1, 2))
+	})
+
+	Context("when the active app manager goes away", func() {
+		BeforeEach(func() {
+			runner.Stop()
+		})
+
+		It("hands over to the other app manager", func() {
+			Eventually(otherRunner.Session, 10).Should(gbytes.Say("app-manager.lock.acquired-lock"))
+
+			err := bbs.DesireLRP(desiredLRP)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Eventually(bbs.GetAllLRPStartAuctions).Should(HaveLen(3))
+		})
+	})
+})
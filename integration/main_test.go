@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/cloudfoundry-incubator/app-manager/integration/app_manager_runner"
@@ -16,6 +17,7 @@ var appManagerPath string
 var etcdRunner *etcdstorerunner.ETCDClusterRunner
 var natsRunner *natsrunner.NATSRunner
 var natsPort int
+var listenAddr string
 var fileServerPresence services_bbs.Presence
 var runner *app_manager_runner.AppManagerRunner
 
@@ -31,6 +33,7 @@ var _ = BeforeSuite(func() {
 
 	etcdPort := 5001 + GinkgoParallelNode()
 	natsPort = 4001 + GinkgoParallelNode()
+	listenAddr = fmt.Sprintf("127.0.0.1:%d", 6001+GinkgoParallelNode())
 
 	etcdRunner = etcdstorerunner.NewETCDClusterRunner(etcdPort, 1)
 
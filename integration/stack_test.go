@@ -0,0 +1,107 @@
+package integration_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry/storeadapter/test_helpers"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/app-manager/integration/app_manager_runner"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/services_bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Desiring apps on a stack no executor runs", func() {
+	var (
+		bbs               *Bbs.BBS
+		executorPresences []services_bbs.Presence
+	)
+
+	maintainExecutorPresence := func(executorID, stack string) {
+		presence, presenceStatus, err := bbs.MaintainExecutorPresence(time.Second, models.ExecutorPresence{
+			ExecutorID: executorID,
+			Stack:      stack,
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Eventually(presenceStatus).Should(Receive(BeTrue()))
+
+		test_helpers.NewStatusReporter(presenceStatus)
+
+		executorPresences = append(executorPresences, presence)
+	}
+
+	BeforeEach(func() {
+		bbs = Bbs.NewBBS(etcdRunner.Adapter(), timeprovider.NewTimeProvider(), lagertest.NewTestLogger("test"))
+		executorPresences = nil
+
+		var err error
+		var presenceStatus <-chan bool
+
+		fileServerPresence, presenceStatus, err = bbs.MaintainFileServerPresence(time.Second, "http://some.file.server", "file-server-id")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Eventually(presenceStatus).Should(Receive(BeTrue()))
+
+		test_helpers.NewStatusReporter(presenceStatus)
+
+		maintainExecutorPresence("executor-1", "some-other-stack")
+
+		runner = app_manager_runner.New(appManagerPath, etcdRunner.NodeURLS(), map[string]string{
+			"some-stack": "some-health-check.tgz",
+		}, listenAddr)
+
+		runner.Start("-stackRefreshInterval", "100ms")
+
+		err = bbs.DesireLRP(models.DesiredLRP{
+			ProcessGuid: "the-guid",
+
+			Stack: "some-stack",
+
+			Instances: 2,
+			MemoryMB:  128,
+			DiskMB:    512,
+
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.RunAction{
+						Path: "the-start-command",
+					},
+				},
+			},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		runner.KillWithFire()
+		fileServerPresence.Remove()
+
+		for _, presence := range executorPresences {
+			presence.Remove()
+		}
+	})
+
+	It("holds the app instead of auctioning it", func() {
+		Eventually(runner.Session).Should(gbytes.Say("pending-unschedulable"))
+		Consistently(bbs.GetAllLRPStartAuctions).Should(BeEmpty())
+	})
+
+	Context("when an executor that runs the stack comes along", func() {
+		BeforeEach(func() {
+			Eventually(runner.Session).Should(gbytes.Say("pending-unschedulable"))
+
+			maintainExecutorPresence("executor-2", "some-stack")
+		})
+
+		It("auctions the app", func() {
+			Eventually(bbs.GetAllLRPStartAuctions).Should(HaveLen(2))
+		})
+	})
+})
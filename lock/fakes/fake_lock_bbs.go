@@ -0,0 +1,60 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/lock"
+)
+
+type FakeLockBBS struct {
+	MaintainAppManagerLockStub        func(interval time.Duration, appManagerID string) (<-chan bool, chan<- chan bool, error)
+	maintainAppManagerLockMutex       sync.RWMutex
+	maintainAppManagerLockArgsForCall []struct {
+		interval     time.Duration
+		appManagerID string
+	}
+	maintainAppManagerLockReturns struct {
+		result1 <-chan bool
+		result2 chan<- chan bool
+		result3 error
+	}
+}
+
+func (fake *FakeLockBBS) MaintainAppManagerLock(interval time.Duration, appManagerID string) (<-chan bool, chan<- chan bool, error) {
+	fake.maintainAppManagerLockMutex.Lock()
+	defer fake.maintainAppManagerLockMutex.Unlock()
+	fake.maintainAppManagerLockArgsForCall = append(fake.maintainAppManagerLockArgsForCall, struct {
+		interval     time.Duration
+		appManagerID string
+	}{interval, appManagerID})
+	if fake.MaintainAppManagerLockStub != nil {
+		return fake.MaintainAppManagerLockStub(interval, appManagerID)
+	} else {
+		return fake.maintainAppManagerLockReturns.result1, fake.maintainAppManagerLockReturns.result2, fake.maintainAppManagerLockReturns.result3
+	}
+}
+
+func (fake *FakeLockBBS) MaintainAppManagerLockCallCount() int {
+	fake.maintainAppManagerLockMutex.RLock()
+	defer fake.maintainAppManagerLockMutex.RUnlock()
+	return len(fake.maintainAppManagerLockArgsForCall)
+}
+
+func (fake *FakeLockBBS) MaintainAppManagerLockArgsForCall(i int) (time.Duration, string) {
+	fake.maintainAppManagerLockMutex.RLock()
+	defer fake.maintainAppManagerLockMutex.RUnlock()
+	return fake.maintainAppManagerLockArgsForCall[i].interval, fake.maintainAppManagerLockArgsForCall[i].appManagerID
+}
+
+func (fake *FakeLockBBS) MaintainAppManagerLockReturns(result1 <-chan bool, result2 chan<- chan bool, result3 error) {
+	fake.MaintainAppManagerLockStub = nil
+	fake.maintainAppManagerLockReturns = struct {
+		result1 <-chan bool
+		result2 chan<- chan bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+var _ lock.LockBBS = new(FakeLockBBS)
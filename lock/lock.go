@@ -0,0 +1,122 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+var ErrLockLost = errors.New("lost the app manager lock")
+
+type Lock struct {
+	bbs          LockBBS
+	appManagerID string
+	interval     time.Duration
+	runner       ifrit.Runner
+	logger       lager.Logger
+}
+
+// New returns a runner that only runs the given runner while holding the
+// app manager lock, and stops it as soon as the lock is lost.
+func New(
+	bbs LockBBS,
+	appManagerID string,
+	interval time.Duration,
+	runner ifrit.Runner,
+	logger lager.Logger,
+) Lock {
+	return Lock{
+		bbs:          bbs,
+		appManagerID: appManagerID,
+		interval:     interval,
+		runner:       runner,
+		logger:       logger.Session("lock", lager.Data{"app-manager-id": appManagerID}),
+	}
+}
+
+func (l Lock) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	lockStatus, releaseLock, err := l.bbs.MaintainAppManagerLock(l.interval, l.appManagerID)
+	if err != nil {
+		l.logger.Error("failed-to-maintain-lock", err)
+		return err
+	}
+
+	l.logger.Info("acquiring-lock")
+
+	close(ready)
+
+	var process ifrit.Process
+	var processExited <-chan error
+
+	for {
+		select {
+		case held, ok := <-lockStatus:
+			switch {
+			case !ok:
+				l.logger.Error("lost-lock", nil)
+				l.stop(process, processExited)
+				return ErrLockLost
+
+			case held && process == nil:
+				l.logger.Info("acquired-lock")
+				process = ifrit.Envoke(l.runner)
+				processExited = process.Wait()
+
+			case !held && process != nil:
+				l.logger.Error("lost-lock", nil)
+				l.stop(process, processExited)
+				l.release(lockStatus, releaseLock)
+				return ErrLockLost
+			}
+
+		case signal := <-signals:
+			l.logger.Info("shutting-down")
+
+			var err error
+			if process != nil {
+				process.Signal(signal)
+				err = <-processExited
+			}
+
+			l.release(lockStatus, releaseLock)
+			l.logger.Info("shut-down")
+			return err
+
+		case err := <-processExited:
+			l.release(lockStatus, releaseLock)
+			return err
+		}
+	}
+}
+
+func (l Lock) stop(process ifrit.Process, processExited <-chan error) {
+	if process != nil {
+		process.Signal(os.Interrupt)
+		<-processExited
+	}
+}
+
+// release keeps draining the lock status while releasing, as the store only
+// notices the release once it is done reporting the status.
+func (l Lock) release(lockStatus <-chan bool, releaseLock chan<- chan bool) {
+	released := make(chan bool)
+
+	for {
+		select {
+		case releaseLock <- released:
+			releaseLock = nil
+
+		case <-released:
+			l.logger.Info("released-lock")
+			return
+
+		case _, ok := <-lockStatus:
+			if !ok {
+				lockStatus = nil
+			}
+		}
+	}
+}
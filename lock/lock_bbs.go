@@ -0,0 +1,32 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/shared"
+	"github.com/cloudfoundry/storeadapter"
+)
+
+const AppManagerLockName = "app_manager_lock"
+
+type LockBBS interface {
+	MaintainAppManagerLock(interval time.Duration, appManagerID string) (<-chan bool, chan<- chan bool, error)
+}
+
+type lockBBS struct {
+	store storeadapter.StoreAdapter
+}
+
+func NewLockBBS(store storeadapter.StoreAdapter) LockBBS {
+	return &lockBBS{
+		store: store,
+	}
+}
+
+func (bbs *lockBBS) MaintainAppManagerLock(interval time.Duration, appManagerID string) (<-chan bool, chan<- chan bool, error) {
+	return bbs.store.MaintainNode(storeadapter.StoreNode{
+		Key:   shared.LockSchemaPath(AppManagerLockName),
+		Value: []byte(appManagerID),
+		TTL:   uint64(interval.Seconds()),
+	})
+}
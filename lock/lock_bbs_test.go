@@ -0,0 +1,29 @@
+package lock_test
+
+import (
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/lock"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LockBBS", func() {
+	var store *fakestoreadapter.FakeStoreAdapter
+
+	BeforeEach(func() {
+		store = fakestoreadapter.New()
+	})
+
+	Describe("MaintainAppManagerLock", func() {
+		It("maintains the app manager lock node with the given id and ttl", func() {
+			_, _, err := NewLockBBS(store).MaintainAppManagerLock(10*time.Second, "some-app-manager-id")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(store.MaintainedNodeName).Should(Equal("/v1/locks/app_manager_lock"))
+			Ω(store.MaintainedNodeValue).Should(Equal([]byte("some-app-manager-id")))
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package lock_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/lock"
+	"github.com/cloudfoundry-incubator/app-manager/lock/fakes"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lock", func() {
+	var (
+		bbs         *fakes.FakeLockBBS
+		lockStatus  chan bool
+		releaseLock chan chan bool
+
+		started  chan struct{}
+		signaled chan os.Signal
+		runErr   error
+
+		lockProcess ifrit.Process
+	)
+
+	BeforeEach(func() {
+		bbs = new(fakes.FakeLockBBS)
+		lockStatus = make(chan bool)
+		releaseLock = make(chan chan bool, 1)
+		bbs.MaintainAppManagerLockReturns(lockStatus, releaseLock, nil)
+
+		started = make(chan struct{}, 1)
+		signaled = make(chan os.Signal, 1)
+		runErr = nil
+	})
+
+	JustBeforeEach(func() {
+		runner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+			started <- struct{}{}
+			close(ready)
+			signaled <- <-signals
+			return runErr
+		})
+
+		lockProcess = ifrit.Envoke(New(bbs, "some-app-manager-id", 10*time.Second, runner, lagertest.NewTestLogger("test")))
+	})
+
+	It("maintains the lock with the app manager id and interval", func() {
+		Ω(bbs.MaintainAppManagerLockCallCount()).Should(Equal(1))
+
+		interval, appManagerID := bbs.MaintainAppManagerLockArgsForCall(0)
+		Ω(interval).Should(Equal(10 * time.Second))
+		Ω(appManagerID).Should(Equal("some-app-manager-id"))
+	})
+
+	Context("before the lock is acquired", func() {
+		AfterEach(func() {
+			lockProcess.Signal(syscall.SIGINT)
+
+			var released chan bool
+			Eventually(releaseLock).Should(Receive(&released))
+			close(released)
+
+			Eventually(lockProcess.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("does not start the runner", func() {
+			Consistently(started).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the lock is acquired", func() {
+		JustBeforeEach(func() {
+			lockStatus <- true
+		})
+
+		It("starts the runner", func() {
+			Eventually(started).Should(Receive())
+
+			lockProcess.Signal(syscall.SIGINT)
+
+			var released chan bool
+			Eventually(releaseLock).Should(Receive(&released))
+			close(released)
+
+			Eventually(lockProcess.Wait()).Should(Receive(BeNil()))
+		})
+
+		Context("and the lock is renewed", func() {
+			JustBeforeEach(func() {
+				Eventually(started).Should(Receive())
+				lockStatus <- true
+			})
+
+			It("does not start the runner again", func() {
+				Consistently(started).ShouldNot(Receive())
+
+				lockProcess.Signal(syscall.SIGINT)
+
+				var released chan bool
+				Eventually(releaseLock).Should(Receive(&released))
+				close(released)
+			})
+		})
+
+		Context("when signaled", func() {
+			var released chan bool
+
+			JustBeforeEach(func() {
+				Eventually(started).Should(Receive())
+				lockProcess.Signal(syscall.SIGTERM)
+
+				Eventually(releaseLock).Should(Receive(&released))
+				close(released)
+			})
+
+			It("forwards the signal to the runner and releases the lock", func() {
+				Ω(signaled).Should(Receive(Equal(syscall.SIGTERM)))
+				Eventually(lockProcess.Wait()).Should(Receive(BeNil()))
+			})
+
+			Context("when the runner exits with an error", func() {
+				BeforeEach(func() {
+					runErr = errors.New("oh no")
+				})
+
+				It("exits with the runner's error", func() {
+					Eventually(lockProcess.Wait()).Should(Receive(Equal(runErr)))
+				})
+			})
+		})
+
+		Context("and then lost", func() {
+			JustBeforeEach(func() {
+				Eventually(started).Should(Receive())
+				lockStatus <- false
+			})
+
+			It("stops the runner, releases the lock, and exits with ErrLockLost", func() {
+				Eventually(signaled).Should(Receive(Equal(os.Interrupt)))
+
+				var released chan bool
+				Eventually(releaseLock).Should(Receive(&released))
+				close(released)
+
+				Eventually(lockProcess.Wait()).Should(Receive(Equal(ErrLockLost)))
+			})
+		})
+
+		Context("and the lock status channel closes", func() {
+			JustBeforeEach(func() {
+				Eventually(started).Should(Receive())
+				close(lockStatus)
+			})
+
+			It("stops the runner and exits with ErrLockLost", func() {
+				Eventually(signaled).Should(Receive(Equal(os.Interrupt)))
+				Eventually(lockProcess.Wait()).Should(Receive(Equal(ErrLockLost)))
+			})
+		})
+	})
+
+	Context("when maintaining the lock fails", func() {
+		disaster := errors.New("oh no")
+
+		BeforeEach(func() {
+			bbs.MaintainAppManagerLockReturns(nil, nil, disaster)
+		})
+
+		It("exits with the error without starting the runner", func() {
+			Eventually(lockProcess.Wait()).Should(Receive(Equal(disaster)))
+			Ω(started).ShouldNot(Receive())
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package lrpreprocessor
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+const (
+	healthCheckDownloadPath = "/tmp/diego-health-check"
+	healthCheckBinaryPath   = healthCheckDownloadPath + "/diego-health-check"
+	defaultHealthCheckPort  = 8080
+)
+
+type LRPreProcessor struct {
+	bbs                       Bbs.AppManagerBBS
+	healthChecks              map[string]string
+	repAddrRelativeToExecutor string
+}
+
+func New(bbs Bbs.AppManagerBBS, healthChecks map[string]string, repAddrRelativeToExecutor string) *LRPreProcessor {
+	return &LRPreProcessor{
+		bbs:                       bbs,
+		healthChecks:              healthChecks,
+		repAddrRelativeToExecutor: repAddrRelativeToExecutor,
+	}
+}
+
+func (p *LRPreProcessor) PreProcess(lrp models.DesiredLRP, instanceIndex int, instanceGuid string) (models.DesiredLRP, error) {
+	healthCheckPath, found := p.healthChecks[lrp.Stack]
+	if !found {
+		return models.DesiredLRP{}, processor.ErrNoHealthCheckDefined
+	}
+
+	fileServerURL, err := p.bbs.GetAvailableFileServer()
+	if err != nil {
+		return models.DesiredLRP{}, err
+	}
+
+	healthCheckURL, err := healthCheckDownloadURL(fileServerURL, healthCheckPath)
+	if err != nil {
+		return models.DesiredLRP{}, err
+	}
+
+	monitor := models.ExecutorAction{
+		Action: models.MonitorAction{
+			Action: models.ExecutorAction{
+				Action: models.RunAction{
+					Path: healthCheckBinaryPath,
+					Args: []string{fmt.Sprintf("-addr=:%d", healthCheckPort(lrp))},
+				},
+			},
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+			HealthyHook: models.HealthRequest{
+				Method: "PUT",
+				URL: fmt.Sprintf(
+					"http://%s/lrp_running/%s/%s/%s",
+					p.repAddrRelativeToExecutor,
+					lrp.ProcessGuid,
+					strconv.Itoa(instanceIndex),
+					instanceGuid,
+				),
+			},
+		},
+	}
+
+	actions := []models.ExecutorAction{
+		{
+			Action: models.DownloadAction{
+				From:    healthCheckURL,
+				To:      healthCheckDownloadPath,
+				Extract: true,
+			},
+		},
+	}
+
+	for _, action := range lrp.Actions {
+		if _, isRun := action.Action.(models.RunAction); isRun {
+			action = models.Parallel(action, monitor)
+		}
+
+		actions = append(actions, action)
+	}
+
+	lrp.Actions = actions
+
+	return lrp, nil
+}
+
+func healthCheckDownloadURL(fileServerURL string, healthCheckPath string) (string, error) {
+	base, err := url.Parse(fileServerURL)
+	if err != nil {
+		return "", err
+	}
+
+	static := &url.URL{Path: path.Join("v1", "static", healthCheckPath)}
+
+	return base.ResolveReference(static).String(), nil
+}
+
+func healthCheckPort(lrp models.DesiredLRP) uint32 {
+	if len(lrp.Ports) > 0 {
+		return lrp.Ports[0].ContainerPort
+	}
+
+	return defaultHealthCheckPort
+}
@@ -0,0 +1,13 @@
+package lrpreprocessor_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLRPreProcessor(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LRPreProcessor Suite")
+}
@@ -0,0 +1,165 @@
+package lrpreprocessor_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry-incubator/app-manager/lrpreprocessor"
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fake_bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRPreProcessor", func() {
+	var (
+		bbs                       *fake_bbs.FakeAppManagerBBS
+		repAddrRelativeToExecutor string
+		healthChecks              map[string]string
+		desiredLRP                models.DesiredLRP
+
+		preprocessor *LRPreProcessor
+	)
+
+	BeforeEach(func() {
+		bbs = fake_bbs.NewFakeAppManagerBBS()
+		bbs.WhenGettingAvailableFileServer = func() (string, error) {
+			return "http://file-server.com/", nil
+		}
+
+		repAddrRelativeToExecutor = "127.0.0.1:20515"
+
+		healthChecks = map[string]string{
+			"some-stack": "some-health-check.tgz",
+		}
+
+		desiredLRP = models.DesiredLRP{
+			ProcessGuid: "the-app-guid-the-app-version",
+
+			Instances: 2,
+			Stack:     "some-stack",
+
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.DownloadAction{
+						From: "http://some-droplet.tgz",
+						To:   "/app",
+					},
+				},
+				{
+					Action: models.RunAction{
+						Path: "some-run-action-path",
+					},
+				},
+			},
+		}
+
+		preprocessor = New(bbs, healthChecks, repAddrRelativeToExecutor)
+	})
+
+	Describe("PreProcess", func() {
+		var (
+			preprocessedLRP models.DesiredLRP
+			preprocessErr   error
+		)
+
+		JustBeforeEach(func() {
+			preprocessedLRP, preprocessErr = preprocessor.PreProcess(desiredLRP, 1, "some-instance-guid")
+		})
+
+		It("downloads the health check for the stack from the file server first", func() {
+			Ω(preprocessErr).ShouldNot(HaveOccurred())
+
+			Ω(preprocessedLRP.Actions[0]).Should(Equal(models.ExecutorAction{
+				Action: models.DownloadAction{
+					From:    "http://file-server.com/v1/static/some-health-check.tgz",
+					To:      "/tmp/diego-health-check",
+					Extract: true,
+				},
+			}))
+		})
+
+		It("leaves the non-run actions alone", func() {
+			Ω(preprocessErr).ShouldNot(HaveOccurred())
+
+			Ω(preprocessedLRP.Actions[1]).Should(Equal(desiredLRP.Actions[0]))
+		})
+
+		It("runs the run action in parallel with a monitor for the instance", func() {
+			Ω(preprocessErr).ShouldNot(HaveOccurred())
+
+			Ω(preprocessedLRP.Actions).Should(HaveLen(3))
+			Ω(preprocessedLRP.Actions[2]).Should(Equal(models.Parallel(
+				models.ExecutorAction{
+					Action: models.RunAction{
+						Path: "some-run-action-path",
+					},
+				},
+				models.ExecutorAction{
+					Action: models.MonitorAction{
+						Action: models.ExecutorAction{
+							Action: models.RunAction{
+								Path: "/tmp/diego-health-check/diego-health-check",
+								Args: []string{"-addr=:8080"},
+							},
+						},
+						HealthyThreshold:   1,
+						UnhealthyThreshold: 1,
+						HealthyHook: models.HealthRequest{
+							Method: "PUT",
+							URL:    "http://127.0.0.1:20515/lrp_running/the-app-guid-the-app-version/1/some-instance-guid",
+						},
+					},
+				},
+			)))
+		})
+
+		It("does not modify the original LRP's actions", func() {
+			Ω(desiredLRP.Actions[1]).Should(Equal(models.ExecutorAction{
+				Action: models.RunAction{
+					Path: "some-run-action-path",
+				},
+			}))
+		})
+
+		Context("when the LRP exposes a port", func() {
+			BeforeEach(func() {
+				desiredLRP.Ports = []models.PortMapping{
+					{ContainerPort: 5678},
+				}
+			})
+
+			It("health checks that port", func() {
+				parallel := preprocessedLRP.Actions[2].Action.(models.ParallelAction)
+				monitor := parallel.Actions[1].Action.(models.MonitorAction)
+
+				Ω(monitor.Action.Action.(models.RunAction).Args).Should(Equal([]string{"-addr=:5678"}))
+			})
+		})
+
+		Context("when there is no health check for the stack", func() {
+			BeforeEach(func() {
+				desiredLRP.Stack = "some-unknown-stack"
+			})
+
+			It("returns ErrNoHealthCheckDefined", func() {
+				Ω(preprocessErr).Should(Equal(processor.ErrNoHealthCheckDefined))
+			})
+		})
+
+		Context("when no file server is available", func() {
+			disaster := errors.New("no file servers")
+
+			BeforeEach(func() {
+				bbs.WhenGettingAvailableFileServer = func() (string, error) {
+					return "", disaster
+				}
+			})
+
+			It("returns the error", func() {
+				Ω(preprocessErr).Should(Equal(disaster))
+			})
+		})
+	})
+})
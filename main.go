@@ -1,22 +1,46 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
+	"math"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/cloudfoundry-incubator/cf-lager"
 	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
 	"github.com/cloudfoundry/gunk/timeprovider"
+	"github.com/cloudfoundry/storeadapter"
 	"github.com/cloudfoundry/storeadapter/etcdstoreadapter"
 	"github.com/cloudfoundry/storeadapter/workerpool"
+	"github.com/nu7hatch/gouuid"
 	"github.com/pivotal-golang/lager"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
 
+	"github.com/cloudfoundry-incubator/app-manager/auctions"
+	"github.com/cloudfoundry-incubator/app-manager/bulker"
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/app-manager/dryrun"
+	"github.com/cloudfoundry-incubator/app-manager/freshness"
 	"github.com/cloudfoundry-incubator/app-manager/handler"
+	"github.com/cloudfoundry-incubator/app-manager/instanceguid"
+	"github.com/cloudfoundry-incubator/app-manager/lock"
 	"github.com/cloudfoundry-incubator/app-manager/lrpreprocessor"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	"github.com/cloudfoundry-incubator/app-manager/reconciler"
+	"github.com/cloudfoundry-incubator/app-manager/restartpolicy"
+	"github.com/cloudfoundry-incubator/app-manager/retry"
+	"github.com/cloudfoundry-incubator/app-manager/stackcache"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/app-manager/validation"
 )
 
 var etcdCluster = flag.String(
@@ -25,24 +49,353 @@ var etcdCluster = flag.String(
 	"comma-separated list of etcd addresses (http://ip:port)",
 )
 
+var healthChecks = flag.String(
+	"healthChecks",
+	"{}",
+	"JSON map of stack to health check tarball path on the file server",
+)
+
+var repAddrRelativeToExecutor = flag.String(
+	"repAddrRelativeToExecutor",
+	"127.0.0.1:20515",
+	"address of the rep server that should receive health status updates",
+)
+
+var reconciliationStrategy = flag.String(
+	"reconciliationStrategy",
+	reconciler.DeltaForceStrategy,
+	"how to reconcile actual LRPs with desired ones (delta-force, converge, prefer-running or spread)",
+)
+
+var domainReconciliationStrategies = flag.String(
+	"domainReconciliationStrategies",
+	"{}",
+	"JSON map of domain to the reconciliation strategy for its desired LRPs, overriding -reconciliationStrategy",
+)
+
+var bulkInterval = flag.Duration(
+	"bulkInterval",
+	30*time.Second,
+	"interval at which to reconcile all desired LRPs against all actual LRPs",
+)
+
+var actualChangeDebounceTime = flag.Duration(
+	"actualChangeDebounceTime",
+	time.Second,
+	"time to wait after an actual LRP disappears, moves, or starts running before reconciling its process",
+)
+
+var workers = flag.Int(
+	"workers",
+	10,
+	"maximum number of process guids to process concurrently",
+)
+
+var lockTTL = flag.Duration(
+	"lockTTL",
+	10*time.Second,
+	"TTL of the lock that elects the active app manager",
+)
+
+var listenAddr = flag.String(
+	"listenAddr",
+	"0.0.0.0:8090",
+	"address to serve the status API and metrics on",
+)
+
+var statsdAddr = flag.String(
+	"statsdAddr",
+	"",
+	"address of a statsd server to send metrics to (disabled if empty)",
+)
+
+var rollingUpdates = flag.Bool(
+	"rollingUpdates",
+	false,
+	"replace running instances when the spec of their desired LRP changes",
+)
+
+var maxSurge = flag.Int(
+	"maxSurge",
+	1,
+	"number of replacement instances that may start alongside the instances they replace during a rolling update",
+)
+
+var maxUnavailable = flag.Int(
+	"maxUnavailable",
+	0,
+	"number of instances that may be stopped before their replacement is running during a rolling update",
+)
+
+var canaryUpdates = flag.Bool(
+	"canaryUpdates",
+	false,
+	"during a rolling update, replace index 0 first and only go on once its replacement has been running for -canarySoakPeriod",
+)
+
+var canarySoakPeriod = flag.Duration(
+	"canarySoakPeriod",
+	time.Minute,
+	"how long the canary of a rolling update must keep running before the rest of its instances are replaced",
+)
+
+var rollbackDeadline = flag.Duration(
+	"rollbackDeadline",
+	0,
+	"during a rolling update, restore the previous desired LRP if a replacement is not running this long after it was requested or the canary fails (0 disables rollbacks)",
+)
+
+var maxStopsPerReconcile = flag.Int(
+	"maxStopsPerReconcile",
+	0,
+	"number of instances of a process that may be stopped each time it is reconciled, highest index first (0 for no limit)",
+)
+
+var maxStopsPerSecond = flag.Float64(
+	"maxStopsPerSecond",
+	0,
+	"number of instances that may be stopped per second across all processes (0 for no limit)",
+)
+
+var maxStartsPerSecond = flag.Float64(
+	"maxStartsPerSecond",
+	0,
+	"number of start auctions that may be requested per second, taking turns between processes (0 for no limit)",
+)
+
+var startBurst = flag.Int(
+	"startBurst",
+	10,
+	"number of start auctions that may be requested at once when -maxStartsPerSecond is set",
+)
+
+var requireFreshDomains = flag.Bool(
+	"requireFreshDomains",
+	false,
+	"only stop the instances of removed desired LRPs if their domain is known and fresh",
+)
+
+var stackRefreshInterval = flag.Duration(
+	"stackRefreshInterval",
+	0,
+	"how often to refresh the stacks the executors run, holding desired LRPs whose stack none runs (0 to auction regardless)",
+)
+
+var deterministicInstanceGuids = flag.Bool(
+	"deterministicInstanceGuids",
+	false,
+	"derive instance guids from the process guid, index, modified index of the desired LRP and generation, so that requesting the same start twice is a no-op",
+)
+
+var crashBackoff = flag.Duration(
+	"crashBackoff",
+	30*time.Second,
+	"time to wait before restarting an instance that crashed twice in a row, doubling with each further crash",
+)
+
+var maxCrashBackoff = flag.Duration(
+	"maxCrashBackoff",
+	16*time.Minute,
+	"longest time to wait before restarting a crashed instance",
+)
+
+var crashResetInterval = flag.Duration(
+	"crashResetInterval",
+	5*time.Minute,
+	"time an instance must have been up for its earlier crashes to be forgiven",
+)
+
+var maxCrashes = flag.Int(
+	"maxCrashes",
+	0,
+	"number of consecutive crashes after which an instance is no longer restarted (0 to always restart)",
+)
+
+var bbsWriteAttempts = flag.Int(
+	"bbsWriteAttempts",
+	3,
+	"number of times to attempt a start or stop request before giving up on it until the process is next reconciled",
+)
+
+var bbsRetryBackoff = flag.Duration(
+	"bbsRetryBackoff",
+	100*time.Millisecond,
+	"time to wait before retrying a failed start or stop request, doubling with each further attempt",
+)
+
+var maxBbsRetryBackoff = flag.Duration(
+	"maxBbsRetryBackoff",
+	2*time.Second,
+	"longest time to wait before retrying a failed start or stop request",
+)
+
+var dryRun = flag.Bool(
+	"dryRun",
+	false,
+	"log the starts and stops that would be requested instead of requesting them, without taking the lock or rolling back updates",
+)
+
 func main() {
 	flag.Parse()
 
 	logger := cf_lager.New("app-manager")
 
-	bbs := initializeBbs(logger)
+	healthCheckDownloads := map[string]string{}
+	err := json.Unmarshal([]byte(*healthChecks), &healthCheckDownloads)
+	if err != nil {
+		logger.Fatal("invalid-health-checks", err)
+	}
+
+	if *rollingUpdates && *maxSurge+*maxUnavailable < 1 {
+		logger.Fatal("invalid-rolling-update-limits", errors.New("one of maxSurge or maxUnavailable must be positive"))
+	}
+
+	if *maxStartsPerSecond > 0 && *startBurst < 1 {
+		logger.Fatal("invalid-start-burst", errors.New("startBurst must be positive"))
+	}
+
+	if *workers < 1 {
+		logger.Fatal("invalid-workers", errors.New("at least one worker is required"))
+	}
+
+	if *bbsWriteAttempts < 1 {
+		logger.Fatal("invalid-bbs-write-attempts", errors.New("at least one attempt is required"))
+	}
+
+	appManagerID, err := uuid.NewV4()
+	if err != nil {
+		logger.Fatal("failed-to-generate-app-manager-id", err)
+	}
+
+	etcdAdapter := initializeStoreAdapter(logger)
+
+	bbs := Bbs.NewBBS(etcdAdapter, timeprovider.NewTimeProvider(), logger)
+
+	lrpp := lrpreprocessor.New(bbs, healthCheckDownloads, *repAddrRelativeToExecutor)
+
+	lrpReconciler := initializeReconciler(logger)
+
+	registry := status.NewRegistry()
+
+	prometheusEmitter := metrics.NewPrometheusEmitter("app_manager")
+	emitter := initializeEmitter(prometheusEmitter, logger)
+
+	updateStrategy := processor.UpdateStrategy{
+		Rolling:        *rollingUpdates,
+		MaxSurge:       *maxSurge,
+		MaxUnavailable: *maxUnavailable,
+
+		Canary:           *canaryUpdates,
+		CanarySoakPeriod: *canarySoakPeriod,
+
+		RollbackDeadline: *rollbackDeadline,
+	}
+
+	scaleDownLimits := processor.ScaleDownLimits{
+		MaxStopsPerReconcile: *maxStopsPerReconcile,
+	}
+
+	if *maxStopsPerSecond > 0 {
+		scaleDownLimits.StopLimiter = ratelimit.New(*maxStopsPerSecond, int(math.Ceil(*maxStopsPerSecond)), timeprovider.NewTimeProvider())
+	}
+
+	var startThrottle ratelimit.Throttle
+	var startable <-chan string
+	var startQueue *ratelimit.FairQueue
+	if *maxStartsPerSecond > 0 {
+		startQueue = ratelimit.NewFairQueue(*maxStartsPerSecond, *startBurst, timeprovider.NewTimeProvider())
+		startThrottle = startQueue
+		startable = startQueue.Ready()
+	}
+
+	var freshnessBBS freshness.FreshnessBBS
+	if *requireFreshDomains {
+		freshnessBBS = freshness.NewFreshnessBBS(etcdAdapter)
+	}
+
+	var stackHolder stackcache.Holder
+	var schedulable <-chan string
+	var stackCache *stackcache.StackCache
+	if *stackRefreshInterval > 0 {
+		stackCache = stackcache.New(bbs, *stackRefreshInterval, timeprovider.NewTimeProvider(), logger)
+		stackHolder = stackCache
+		schedulable = stackCache.Schedulable()
+	}
+
+	instanceGuids := instanceguid.NewRandom()
+	var desiredIndexBBS desiredwatch.DesiredIndexBBS
+	if *deterministicInstanceGuids {
+		instanceGuids = instanceguid.NewDeterministic()
+		desiredIndexBBS = desiredwatch.NewDesiredIndexBBS(etcdAdapter)
+	}
+
+	restartPolicy := restartpolicy.New(
+		*crashBackoff,
+		*maxCrashBackoff,
+		*crashResetInterval,
+		*maxCrashes,
+		timeprovider.NewTimeProvider(),
+	)
+
+	var processorBBS Bbs.AppManagerBBS
+	var rollbackBBS processor.RollbackBBS
+	if *dryRun {
+		processorBBS = dryrun.New(bbs, emitter, logger)
+	} else {
+		processorBBS = retry.New(bbs, *bbsWriteAttempts, *bbsRetryBackoff, *maxBbsRetryBackoff, time.Sleep, emitter, logger)
+		rollbackBBS = bbs
+	}
+
+	processorConfig := processor.Config{
+		UpdateStrategy:  updateStrategy,
+		ScaleDownLimits: scaleDownLimits,
+		RollbackBBS:     rollbackBBS,
+		DesiredIndexBBS: desiredIndexBBS,
+		StartThrottle:   startThrottle,
+		FreshnessBBS:    freshnessBBS,
+		StackHolder:     stackHolder,
+	}
+
+	lrpProcessor := processor.New(processorBBS, auctions.NewAuctionBBS(etcdAdapter), lrpp, validation.New(), instanceGuids, lrpReconciler, restartPolicy, processorConfig, timeprovider.NewTimeProvider(), registry, emitter, logger)
 
-	lrpp := lrpreprocessor.New(bbs)
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", status.NewHandler(registry, logger))
+	mux.Handle("/metrics", prometheusEmitter)
 
-	group := grouper.EnvokeGroup(grouper.RunGroup{
-		"handler": handler.NewHandler(bbs, lrpp, logger),
-	})
+	statusServer := ifrit.Envoke(http_server.New(*listenAddr, mux))
+
+	members := grouper.RunGroup{
+		"handler": handler.NewHandler(bbs, desiredwatch.NewDesiredWatchBBS(etcdAdapter), lrpProcessor, registry, emitter, schedulable, startable, *workers, *actualChangeDebounceTime, logger),
+		"bulker":  bulker.NewBulker(bbs, lrpProcessor, *bulkInterval, timeprovider.NewTimeProvider(), logger),
+	}
+
+	if startQueue != nil {
+		members["start-throttle"] = startQueue
+	}
+
+	if stackCache != nil {
+		members["stack-cache"] = stackCache
+	}
+
+	var appManager ifrit.Runner = members
+
+	if !*dryRun {
+		appManager = lock.New(lock.NewLockBBS(etcdAdapter), appManagerID.String(), *lockTTL, appManager, logger)
+	}
+
+	group := ifrit.Envoke(appManager)
 
 	logger.Info("started")
 
 	monitor := ifrit.Envoke(sigmon.New(group))
 
-	err := <-monitor.Wait()
+	select {
+	case err = <-monitor.Wait():
+	case err = <-statusServer.Wait():
+		logger.Error("status-server-exited", err)
+		monitor.Signal(os.Interrupt)
+		<-monitor.Wait()
+	}
 	if err != nil {
 		logger.Error("exited-with-failure", err)
 		os.Exit(1)
@@ -51,7 +404,43 @@ func main() {
 	logger.Info("exited")
 }
 
-func initializeBbs(logger lager.Logger) Bbs.AppManagerBBS {
+func initializeEmitter(prometheusEmitter *metrics.PrometheusEmitter, logger lager.Logger) metrics.Emitter {
+	if *statsdAddr == "" {
+		return prometheusEmitter
+	}
+
+	statsdEmitter, err := metrics.NewStatsdEmitter(*statsdAddr, "app_manager")
+	if err != nil {
+		logger.Fatal("failed-to-initialize-statsd", err)
+	}
+
+	return metrics.Emitters{prometheusEmitter, statsdEmitter}
+}
+
+func initializeReconciler(logger lager.Logger) reconciler.Reconciler {
+	fallback, err := reconciler.New(*reconciliationStrategy)
+	if err != nil {
+		logger.Fatal("invalid-reconciliation-strategy", err)
+	}
+
+	strategies := map[string]string{}
+	err = json.Unmarshal([]byte(*domainReconciliationStrategies), &strategies)
+	if err != nil {
+		logger.Fatal("invalid-domain-reconciliation-strategies", err)
+	}
+
+	reconcilers := map[string]reconciler.Reconciler{}
+	for domain, strategy := range strategies {
+		reconcilers[domain], err = reconciler.New(strategy)
+		if err != nil {
+			logger.Fatal("invalid-domain-reconciliation-strategies", err, lager.Data{"domain": domain})
+		}
+	}
+
+	return reconciler.ByDomain(reconcilers, fallback)
+}
+
+func initializeStoreAdapter(logger lager.Logger) storeadapter.StoreAdapter {
 	etcdAdapter := etcdstoreadapter.NewETCDStoreAdapter(
 		strings.Split(*etcdCluster, ","),
 		workerpool.NewWorkerPool(10),
@@ -62,5 +451,5 @@ func initializeBbs(logger lager.Logger) Bbs.AppManagerBBS {
 		logger.Fatal("failed-to-connect-to-etcd", err)
 	}
 
-	return Bbs.NewAppManagerBBS(etcdAdapter, timeprovider.NewTimeProvider(), logger)
+	return etcdAdapter
 }
@@ -0,0 +1,58 @@
+package metrics
+
+import "time"
+
+const (
+	StartsRequested        = "starts_requested"
+	StartsThrottled        = "starts_throttled"
+	StopInstancesRequested = "stop_instances_requested"
+	StopsDeferred          = "stops_deferred"
+	StopAuctionsRequested  = "stop_auctions_requested"
+	InFlightSkips          = "in_flight_skips"
+	StaleDomainSkips       = "stale_domain_skips"
+	UnschedulableHolds     = "unschedulable_holds"
+	InvalidDesiredLRPs     = "invalid_desired_lrps"
+	PreprocessFailures     = "preprocess_failures"
+	BBSWriteFailures       = "bbs_write_failures"
+	BBSWriteRetries        = "bbs_write_retries"
+	WatchReconnects        = "watch_reconnects"
+	StaleDesiredChanges    = "stale_desired_changes"
+	RestartsBackedOff      = "restarts_backed_off"
+	RestartsGivenUp        = "restarts_given_up"
+	CanariesFailed         = "canaries_failed"
+	UpdatesRolledBack      = "updates_rolled_back"
+
+	DryRunStarts        = "dry_run_starts"
+	DryRunStopInstances = "dry_run_stop_instances"
+	DryRunStopAuctions  = "dry_run_stop_auctions"
+
+	DesiredChangeDuration = "desired_change_duration"
+	ReconcileDuration     = "reconcile_duration"
+)
+
+type Emitter interface {
+	IncrementCounter(name string)
+	AddToCounter(name string, delta uint64)
+	ObserveDuration(name string, duration time.Duration)
+}
+
+// Emitters sends everything it is given to each of its emitters.
+type Emitters []Emitter
+
+func (emitters Emitters) IncrementCounter(name string) {
+	for _, emitter := range emitters {
+		emitter.IncrementCounter(name)
+	}
+}
+
+func (emitters Emitters) AddToCounter(name string, delta uint64) {
+	for _, emitter := range emitters {
+		emitter.AddToCounter(name, delta)
+	}
+}
+
+func (emitters Emitters) ObserveDuration(name string, duration time.Duration) {
+	for _, emitter := range emitters {
+		emitter.ObserveDuration(name, duration)
+	}
+}
@@ -0,0 +1,29 @@
+package metrics_test
+
+import (
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/metrics/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitters", func() {
+	It("emits to each of its emitters", func() {
+		first := fakes.NewFakeEmitter()
+		second := fakes.NewFakeEmitter()
+
+		emitters := Emitters{first, second}
+		emitters.IncrementCounter(WatchReconnects)
+		emitters.AddToCounter(StopsDeferred, 3)
+		emitters.ObserveDuration(ReconcileDuration, time.Second)
+
+		for _, emitter := range []*fakes.FakeEmitter{first, second} {
+			Ω(emitter.Counter(WatchReconnects)).Should(Equal(1))
+			Ω(emitter.Counter(StopsDeferred)).Should(Equal(3))
+			Ω(emitter.Durations(ReconcileDuration)).Should(Equal([]time.Duration{time.Second}))
+		}
+	})
+})
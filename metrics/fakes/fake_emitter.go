@@ -0,0 +1,59 @@
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+)
+
+type FakeEmitter struct {
+	counters  map[string]int
+	durations map[string][]time.Duration
+
+	sync.RWMutex
+}
+
+func NewFakeEmitter() *FakeEmitter {
+	return &FakeEmitter{
+		counters:  map[string]int{},
+		durations: map[string][]time.Duration{},
+	}
+}
+
+func (fakeEmitter *FakeEmitter) IncrementCounter(name string) {
+	fakeEmitter.Lock()
+	defer fakeEmitter.Unlock()
+
+	fakeEmitter.counters[name]++
+}
+
+func (fakeEmitter *FakeEmitter) AddToCounter(name string, delta uint64) {
+	fakeEmitter.Lock()
+	defer fakeEmitter.Unlock()
+
+	fakeEmitter.counters[name] += int(delta)
+}
+
+func (fakeEmitter *FakeEmitter) ObserveDuration(name string, duration time.Duration) {
+	fakeEmitter.Lock()
+	defer fakeEmitter.Unlock()
+
+	fakeEmitter.durations[name] = append(fakeEmitter.durations[name], duration)
+}
+
+func (fakeEmitter *FakeEmitter) Counter(name string) int {
+	fakeEmitter.RLock()
+	defer fakeEmitter.RUnlock()
+
+	return fakeEmitter.counters[name]
+}
+
+func (fakeEmitter *FakeEmitter) Durations(name string) []time.Duration {
+	fakeEmitter.RLock()
+	defer fakeEmitter.RUnlock()
+
+	return fakeEmitter.durations[name]
+}
+
+var _ metrics.Emitter = NewFakeEmitter()
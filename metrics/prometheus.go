@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the histogram buckets
+// durations are counted in.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusEmitter keeps counters and histograms in memory and serves them
+// in the Prometheus text exposition format.
+type PrometheusEmitter struct {
+	namespace string
+
+	counters   map[string]uint64
+	histograms map[string]*histogram
+	lock       sync.Mutex
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func NewPrometheusEmitter(namespace string) *PrometheusEmitter {
+	return &PrometheusEmitter{
+		namespace:  namespace,
+		counters:   map[string]uint64{},
+		histograms: map[string]*histogram{},
+	}
+}
+
+func (e *PrometheusEmitter) IncrementCounter(name string) {
+	e.AddToCounter(name, 1)
+}
+
+func (e *PrometheusEmitter) AddToCounter(name string, delta uint64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.counters[name] += delta
+}
+
+func (e *PrometheusEmitter) ObserveDuration(name string, duration time.Duration) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	h, ok := e.histograms[name]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+		e.histograms[name] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, upperBound := range DurationBuckets {
+		if seconds <= upperBound {
+			h.buckets[i]++
+		}
+	}
+
+	h.sum += seconds
+	h.count++
+}
+
+func (e *PrometheusEmitter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	counters, histograms := e.snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	counterNames := []string{}
+	for name := range counters {
+		counterNames = append(counterNames, name)
+	}
+	sort.Strings(counterNames)
+
+	for _, name := range counterNames {
+		metric := e.namespace + "_" + name + "_total"
+		fmt.Fprintf(w, "# TYPE %s counter\n", metric)
+		fmt.Fprintf(w, "%s %d\n", metric, counters[name])
+	}
+
+	histogramNames := []string{}
+	for name := range histograms {
+		histogramNames = append(histogramNames, name)
+	}
+	sort.Strings(histogramNames)
+
+	for _, name := range histogramNames {
+		h := histograms[name]
+		metric := e.namespace + "_" + name + "_seconds"
+
+		fmt.Fprintf(w, "# TYPE %s histogram\n", metric)
+		for i, upperBound := range DurationBuckets {
+			fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", metric, formatFloat(upperBound), h.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", metric, h.count)
+		fmt.Fprintf(w, "%s_sum %s\n", metric, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count %d\n", metric, h.count)
+	}
+}
+
+// snapshot copies the counters and histograms, so that a slow scrape does
+// not hold up everything that emits metrics while it is written out.
+func (e *PrometheusEmitter) snapshot() (map[string]uint64, map[string]histogram) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	counters := make(map[string]uint64, len(e.counters))
+	for name, value := range e.counters {
+		counters[name] = value
+	}
+
+	histograms := make(map[string]histogram, len(e.histograms))
+	for name, h := range e.histograms {
+		histograms[name] = histogram{
+			buckets: append([]uint64(nil), h.buckets...),
+			sum:     h.sum,
+			count:   h.count,
+		}
+	}
+
+	return counters, histograms
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,124 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrometheusEmitter", func() {
+	var emitter *PrometheusEmitter
+
+	BeforeEach(func() {
+		emitter = NewPrometheusEmitter("some_namespace")
+	})
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		emitter.ServeHTTP(recorder, request)
+		Ω(recorder.Code).Should(Equal(http.StatusOK))
+
+		return recorder.Body.String()
+	}
+
+	It("serves nothing before anything is emitted", func() {
+		Ω(scrape()).Should(BeEmpty())
+	})
+
+	It("serves counters", func() {
+		emitter.IncrementCounter(StartsRequested)
+		emitter.IncrementCounter(StartsRequested)
+		emitter.IncrementCounter(BBSWriteFailures)
+		emitter.AddToCounter(StopsDeferred, 3)
+
+		Ω(scrape()).Should(Equal(
+			"# TYPE some_namespace_bbs_write_failures_total counter\n" +
+				"some_namespace_bbs_write_failures_total 1\n" +
+				"# TYPE some_namespace_starts_requested_total counter\n" +
+				"some_namespace_starts_requested_total 2\n" +
+				"# TYPE some_namespace_stops_deferred_total counter\n" +
+				"some_namespace_stops_deferred_total 3\n",
+		))
+	})
+
+	It("serves durations as histograms in seconds", func() {
+		emitter.ObserveDuration(ReconcileDuration, 20*time.Millisecond)
+		emitter.ObserveDuration(ReconcileDuration, 3*time.Second)
+		emitter.ObserveDuration(ReconcileDuration, time.Minute)
+
+		Ω(scrape()).Should(Equal(
+			"# TYPE some_namespace_reconcile_duration_seconds histogram\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.005\"} 0\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.01\"} 0\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.025\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.05\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.1\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.25\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"0.5\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"1\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"2.5\"} 1\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"5\"} 2\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"10\"} 2\n" +
+				"some_namespace_reconcile_duration_seconds_bucket{le=\"+Inf\"} 3\n" +
+				"some_namespace_reconcile_duration_seconds_sum 63.02\n" +
+				"some_namespace_reconcile_duration_seconds_count 3\n",
+		))
+	})
+
+	It("does not hold up emitters while a scrape is being written", func() {
+		emitter.IncrementCounter(StartsRequested)
+
+		writer := &blockingResponseWriter{
+			ResponseRecorder: httptest.NewRecorder(),
+			writing:          make(chan struct{}),
+			release:          make(chan struct{}),
+		}
+
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		served := make(chan struct{})
+		go func() {
+			emitter.ServeHTTP(writer, request)
+			close(served)
+		}()
+
+		Eventually(writer.writing).Should(BeClosed())
+
+		incremented := make(chan struct{})
+		go func() {
+			emitter.IncrementCounter(StartsRequested)
+			emitter.ObserveDuration(ReconcileDuration, time.Second)
+			close(incremented)
+		}()
+
+		Eventually(incremented).Should(BeClosed())
+
+		close(writer.release)
+		Eventually(served).Should(BeClosed())
+	})
+})
+
+type blockingResponseWriter struct {
+	*httptest.ResponseRecorder
+
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingResponseWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
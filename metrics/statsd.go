@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// StatsdEmitter sends each counter increment and duration as its own UDP
+// packet. Packets that cannot be sent are dropped.
+type StatsdEmitter struct {
+	prefix string
+	conn   net.Conn
+}
+
+func NewStatsdEmitter(address string, prefix string) (*StatsdEmitter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsdEmitter{
+		prefix: prefix,
+		conn:   conn,
+	}, nil
+}
+
+func (e *StatsdEmitter) IncrementCounter(name string) {
+	e.AddToCounter(name, 1)
+}
+
+func (e *StatsdEmitter) AddToCounter(name string, delta uint64) {
+	e.send(fmt.Sprintf("%s.%s:%d|c", e.prefix, name, delta))
+}
+
+func (e *StatsdEmitter) ObserveDuration(name string, duration time.Duration) {
+	milliseconds := float64(duration) / float64(time.Millisecond)
+	e.send(fmt.Sprintf("%s.%s:%s|ms", e.prefix, name, formatFloat(milliseconds)))
+}
+
+func (e *StatsdEmitter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *StatsdEmitter) send(stat string) {
+	e.conn.Write([]byte(stat))
+}
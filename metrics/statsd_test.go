@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"net"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsdEmitter", func() {
+	var (
+		listener net.PacketConn
+		emitter  *StatsdEmitter
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		listener, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		emitter, err = NewStatsdEmitter(listener.LocalAddr().String(), "some-prefix")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		emitter.Close()
+		listener.Close()
+	})
+
+	receive := func() string {
+		buffer := make([]byte, 1024)
+
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := listener.ReadFrom(buffer)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return string(buffer[:n])
+	}
+
+	It("sends counter increments", func() {
+		emitter.IncrementCounter(StopAuctionsRequested)
+
+		Ω(receive()).Should(Equal("some-prefix.stop_auctions_requested:1|c"))
+	})
+
+	It("sends additions to counters as a single increment", func() {
+		emitter.AddToCounter(StopsDeferred, 3)
+
+		Ω(receive()).Should(Equal("some-prefix.stops_deferred:3|c"))
+	})
+
+	It("sends durations as timings in milliseconds", func() {
+		emitter.ObserveDuration(DesiredChangeDuration, 1500*time.Microsecond)
+
+		Ω(receive()).Should(Equal("some-prefix.desired_change_duration:1.5|ms"))
+	})
+})
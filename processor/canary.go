@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+const canaryIndex = 0
+
+// A canary is the first replacement started by an update. The rest of the
+// update waits until it has been running for the soak period; if it goes
+// away before then, the update halts where it is.
+type canary struct {
+	instanceGuid string
+	seen         bool
+	runningSince time.Time
+	passed       bool
+	failed       bool
+}
+
+// checkCanary reports whether the update may replace indices other than the
+// canary's. Without canaries, it always may.
+func (p *processor) checkCanary(logger lager.Logger, report *status.ProcessStatus, update *rollingUpdate, actualLRPs []models.ActualLRP) bool {
+	if !p.updateStrategy.Canary || update.desiredLRP.Instances == 0 || update.canary.passed {
+		return true
+	}
+
+	report.CanaryFailed = update.canary.failed
+	if update.canary.failed {
+		return false
+	}
+
+	// An instance that was already on its way to the canary index when the
+	// update began is taken to be the canary.
+	if update.canary.instanceGuid == "" {
+		for _, actual := range actualLRPs {
+			if actual.Index == canaryIndex && !update.oldInstances[actual.InstanceGuid] {
+				update.canary.instanceGuid = actual.InstanceGuid
+				break
+			}
+		}
+	}
+
+	if update.canary.instanceGuid == "" {
+		return false
+	}
+
+	var canaryActual *models.ActualLRP
+	for i, actual := range actualLRPs {
+		if actual.InstanceGuid == update.canary.instanceGuid {
+			canaryActual = &actualLRPs[i]
+			break
+		}
+	}
+
+	if canaryActual == nil {
+		if update.canary.seen {
+			logger.Error("canary-failed", nil, lager.Data{
+				"process-guid":  update.desiredLRP.ProcessGuid,
+				"instance-guid": update.canary.instanceGuid,
+			})
+			update.canary.failed = true
+			report.CanaryFailed = true
+			p.emitter.IncrementCounter(metrics.CanariesFailed)
+		}
+
+		return false
+	}
+
+	update.canary.seen = true
+
+	if canaryActual.State != models.ActualLRPStateRunning {
+		return false
+	}
+
+	now := p.timeProvider.Time()
+	if update.canary.runningSince.IsZero() {
+		update.canary.runningSince = now
+	}
+
+	if now.Sub(update.canary.runningSince) < p.updateStrategy.CanarySoakPeriod {
+		return false
+	}
+
+	logger.Info("canary-passed", lager.Data{
+		"process-guid":  update.desiredLRP.ProcessGuid,
+		"instance-guid": update.canary.instanceGuid,
+	})
+	update.canary.passed = true
+
+	return true
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// rememberDomain keeps track of the domain of each desired process, so that
+// it is known once only its actual LRPs are left. The domain is forgotten
+// once those are gone too.
+func (p *processor) rememberDomain(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) string {
+	p.domainsLock.Lock()
+	defer p.domainsLock.Unlock()
+
+	domain := desiredLRP.Domain
+	if domain == "" {
+		domain = p.domains[desiredLRP.ProcessGuid]
+	}
+
+	if desiredInstances == 0 && len(actualLRPs) == 0 {
+		delete(p.domains, desiredLRP.ProcessGuid)
+	} else if domain != "" {
+		p.domains[desiredLRP.ProcessGuid] = domain
+	}
+
+	return domain
+}
+
+// mayStopAll reports whether every instance of a process whose desired LRP
+// was removed may be stopped. That is only the case if the process's domain
+// is fresh; otherwise the stops are left for a later reconcile. A process
+// whose domain is not known, such as one whose actual LRPs outlived an
+// earlier app manager, cannot be shown to be fresh and is not stopped either.
+func (p *processor) mayStopAll(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, domain string) (bool, error) {
+	if p.freshnessBBS == nil {
+		return true, nil
+	}
+
+	if domain == "" {
+		logger.Info("skipping-stop-unknown-domain", lager.Data{
+			"desired-app-message": desiredLRP,
+		})
+		p.emitter.IncrementCounter(metrics.StaleDomainSkips)
+		return false, nil
+	}
+
+	fresh, err := p.freshnessBBS.IsFresh(domain)
+	if err != nil {
+		logger.Error("checking-domain-freshness-failed", err, lager.Data{
+			"desired-app-message": desiredLRP,
+			"domain":              domain,
+		})
+		report.LastError = err.Error()
+		return false, err
+	}
+
+	if !fresh {
+		logger.Info("skipping-stop-stale-domain", lager.Data{
+			"desired-app-message": desiredLRP,
+			"domain":              domain,
+		})
+		p.emitter.IncrementCounter(metrics.StaleDomainSkips)
+		return false, nil
+	}
+
+	return true, nil
+}
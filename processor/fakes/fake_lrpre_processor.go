@@ -2,7 +2,7 @@
 package fakes
 
 import (
-	"github.com/cloudfoundry-incubator/app-manager/handler"
+	"github.com/cloudfoundry-incubator/app-manager/processor"
 	"github.com/cloudfoundry-incubator/runtime-schema/models"
 
 	"sync"
@@ -56,4 +56,4 @@ func (fake *FakeLRPreProcessor) PreProcessReturns(result1 models.DesiredLRP, res
 	}{result1, result2}
 }
 
-var _ handler.LRPreProcessor = new(FakeLRPreProcessor)
+var _ processor.LRPreProcessor = new(FakeLRPreProcessor)
@@ -0,0 +1,86 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeProcessor struct {
+	ProcessDesiredChangeStub        func(desiredChange models.DesiredLRPChange) error
+	processDesiredChangeMutex       sync.RWMutex
+	processDesiredChangeArgsForCall []struct {
+		desiredChange models.DesiredLRPChange
+	}
+	processDesiredChangeReturns struct {
+		result1 error
+	}
+	ReconcileStub        func(desiredLRP models.DesiredLRP, desiredInstances int)
+	reconcileMutex       sync.RWMutex
+	reconcileArgsForCall []struct {
+		desiredLRP       models.DesiredLRP
+		desiredInstances int
+	}
+}
+
+func (fake *FakeProcessor) ProcessDesiredChange(desiredChange models.DesiredLRPChange) error {
+	fake.processDesiredChangeMutex.Lock()
+	fake.processDesiredChangeArgsForCall = append(fake.processDesiredChangeArgsForCall, struct {
+		desiredChange models.DesiredLRPChange
+	}{desiredChange})
+	returns := fake.processDesiredChangeReturns
+	fake.processDesiredChangeMutex.Unlock()
+	if fake.ProcessDesiredChangeStub != nil {
+		return fake.ProcessDesiredChangeStub(desiredChange)
+	} else {
+		return returns.result1
+	}
+}
+
+func (fake *FakeProcessor) ProcessDesiredChangeCallCount() int {
+	fake.processDesiredChangeMutex.RLock()
+	defer fake.processDesiredChangeMutex.RUnlock()
+	return len(fake.processDesiredChangeArgsForCall)
+}
+
+func (fake *FakeProcessor) ProcessDesiredChangeArgsForCall(i int) models.DesiredLRPChange {
+	fake.processDesiredChangeMutex.RLock()
+	defer fake.processDesiredChangeMutex.RUnlock()
+	return fake.processDesiredChangeArgsForCall[i].desiredChange
+}
+
+func (fake *FakeProcessor) ProcessDesiredChangeReturns(result1 error) {
+	fake.processDesiredChangeMutex.Lock()
+	defer fake.processDesiredChangeMutex.Unlock()
+	fake.processDesiredChangeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeProcessor) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int) {
+	fake.reconcileMutex.Lock()
+	fake.reconcileArgsForCall = append(fake.reconcileArgsForCall, struct {
+		desiredLRP       models.DesiredLRP
+		desiredInstances int
+	}{desiredLRP, desiredInstances})
+	fake.reconcileMutex.Unlock()
+	if fake.ReconcileStub != nil {
+		fake.ReconcileStub(desiredLRP, desiredInstances)
+	}
+}
+
+func (fake *FakeProcessor) ReconcileCallCount() int {
+	fake.reconcileMutex.RLock()
+	defer fake.reconcileMutex.RUnlock()
+	return len(fake.reconcileArgsForCall)
+}
+
+func (fake *FakeProcessor) ReconcileArgsForCall(i int) (models.DesiredLRP, int) {
+	fake.reconcileMutex.RLock()
+	defer fake.reconcileMutex.RUnlock()
+	return fake.reconcileArgsForCall[i].desiredLRP, fake.reconcileArgsForCall[i].desiredInstances
+}
+
+var _ processor.Processor = new(FakeProcessor)
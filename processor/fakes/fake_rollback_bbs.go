@@ -0,0 +1,55 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeRollbackBBS struct {
+	ChangeDesiredLRPStub        func(change models.DesiredLRPChange) error
+	changeDesiredLRPMutex       sync.RWMutex
+	changeDesiredLRPArgsForCall []struct {
+		change models.DesiredLRPChange
+	}
+	changeDesiredLRPReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeRollbackBBS) ChangeDesiredLRP(change models.DesiredLRPChange) error {
+	fake.changeDesiredLRPMutex.Lock()
+	defer fake.changeDesiredLRPMutex.Unlock()
+	fake.changeDesiredLRPArgsForCall = append(fake.changeDesiredLRPArgsForCall, struct {
+		change models.DesiredLRPChange
+	}{change})
+	if fake.ChangeDesiredLRPStub != nil {
+		return fake.ChangeDesiredLRPStub(change)
+	} else {
+		return fake.changeDesiredLRPReturns.result1
+	}
+}
+
+func (fake *FakeRollbackBBS) ChangeDesiredLRPCallCount() int {
+	fake.changeDesiredLRPMutex.RLock()
+	defer fake.changeDesiredLRPMutex.RUnlock()
+	return len(fake.changeDesiredLRPArgsForCall)
+}
+
+func (fake *FakeRollbackBBS) ChangeDesiredLRPArgsForCall(i int) models.DesiredLRPChange {
+	fake.changeDesiredLRPMutex.RLock()
+	defer fake.changeDesiredLRPMutex.RUnlock()
+	return fake.changeDesiredLRPArgsForCall[i].change
+}
+
+func (fake *FakeRollbackBBS) ChangeDesiredLRPReturns(result1 error) {
+	fake.changeDesiredLRPMutex.Lock()
+	defer fake.changeDesiredLRPMutex.Unlock()
+	fake.changeDesiredLRPReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ processor.RollbackBBS = new(FakeRollbackBBS)
@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// auctionsInFlight are the indices of a process that have start or stop
+// auctions pending or claimed. Their instances are on the way, so requesting
+// them again would only duplicate them.
+type auctionsInFlight struct {
+	starts map[int]bool
+	stops  map[int]bool
+}
+
+func (p *processor) fetchAuctionsInFlight(processGuid string) (auctionsInFlight, error) {
+	inFlight := auctionsInFlight{
+		starts: map[int]bool{},
+		stops:  map[int]bool{},
+	}
+
+	startAuctions, err := p.auctionBBS.GetLRPStartAuctionsByProcessGuid(processGuid)
+	if err != nil {
+		return inFlight, err
+	}
+
+	for _, startAuction := range startAuctions {
+		inFlight.starts[startAuction.Index] = true
+	}
+
+	stopAuctions, err := p.auctionBBS.GetLRPStopAuctionsByProcessGuid(processGuid)
+	if err != nil {
+		return inFlight, err
+	}
+
+	for _, stopAuction := range stopAuctions {
+		inFlight.stops[stopAuction.Index] = true
+	}
+
+	return inFlight, nil
+}
+
+func (p *processor) startInFlight(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, inFlight auctionsInFlight, lrpIndex int) bool {
+	if !inFlight.starts[lrpIndex] {
+		return false
+	}
+
+	logger.Info("start-already-in-flight", lager.Data{
+		"desired-app-message": desiredLRP,
+		"index":               lrpIndex,
+	})
+	report.StartsInFlight = append(report.StartsInFlight, lrpIndex)
+	p.emitter.IncrementCounter(metrics.InFlightSkips)
+
+	return true
+}
+
+func (p *processor) stopAuctionInFlight(logger lager.Logger, desiredLRP models.DesiredLRP, inFlight auctionsInFlight, lrpIndex int) bool {
+	if !inFlight.stops[lrpIndex] {
+		return false
+	}
+
+	logger.Info("stop-auction-already-in-flight", lager.Data{
+		"desired-app-message":  desiredLRP,
+		"stop-duplicate-index": lrpIndex,
+	})
+	p.emitter.IncrementCounter(metrics.InFlightSkips)
+
+	return true
+}
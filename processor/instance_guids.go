@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// The generations of a process count the instances started at each of its
+// indices since the desired LRP was last modified. Instance guids are derived
+// from the modified index of the desired LRP as well, so that an app manager
+// that comes up without them does not start over with guids it handed out
+// for an earlier version.
+type processGenerations struct {
+	desiredIndex uint64
+	indices      map[int]*indexGeneration
+}
+
+// An indexGeneration is the generation of the instance last started at an
+// index, and whether that instance has shown up among the actual LRPs yet.
+type indexGeneration struct {
+	generation   int
+	instanceGuid string
+	seen         bool
+}
+
+// observeDesiredIndex looks up the modified index of the desired LRP, and
+// starts counting generations afresh when it has changed. Without a
+// DesiredIndexBBS every version of the desired LRP is taken to be the same.
+func (p *processor) observeDesiredIndex(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP) error {
+	if p.desiredIndexBBS == nil {
+		return nil
+	}
+
+	desiredIndex, err := p.desiredIndexBBS.GetDesiredLRPIndex(desiredLRP.ProcessGuid)
+	if err != nil {
+		logger.Error("fetch-desired-index-failed", err, lager.Data{"desired-app-message": desiredLRP})
+		report.LastError = err.Error()
+		return err
+	}
+
+	p.generationsLock.Lock()
+	defer p.generationsLock.Unlock()
+
+	generations := p.generations[desiredLRP.ProcessGuid]
+	if generations == nil || generations.desiredIndex != desiredIndex {
+		p.generations[desiredLRP.ProcessGuid] = &processGenerations{
+			desiredIndex: desiredIndex,
+			indices:      map[int]*indexGeneration{},
+		}
+	}
+
+	return nil
+}
+
+// observeGenerations notes which of the instances started at each index have
+// shown up, so that the next instance started there is a generation later.
+// Until then, starting the index again starts the same generation.
+func (p *processor) observeGenerations(processGuid string, actualLRPs []models.ActualLRP) {
+	p.generationsLock.Lock()
+	defer p.generationsLock.Unlock()
+
+	generations := p.generations[processGuid]
+	if generations == nil {
+		return
+	}
+
+	for _, actual := range actualLRPs {
+		indexGen := generations.indices[actual.Index]
+		if indexGen != nil && indexGen.instanceGuid == actual.InstanceGuid {
+			indexGen.seen = true
+		}
+	}
+}
+
+// nextInstanceGuid returns the guid to start an index with, skipping any
+// generation whose guid is already taken by one of the actual LRPs.
+func (p *processor) nextInstanceGuid(processGuid string, index int, actualLRPs []models.ActualLRP) (string, error) {
+	p.generationsLock.Lock()
+	defer p.generationsLock.Unlock()
+
+	generations, found := p.generations[processGuid]
+	if !found {
+		generations = &processGenerations{indices: map[int]*indexGeneration{}}
+		p.generations[processGuid] = generations
+	}
+
+	indexGen, found := generations.indices[index]
+	if !found {
+		indexGen = &indexGeneration{}
+		generations.indices[index] = indexGen
+	} else if indexGen.seen {
+		indexGen.generation++
+		indexGen.seen = false
+	}
+
+	for {
+		instanceGuid, err := p.instanceGuids.InstanceGuid(processGuid, index, generations.desiredIndex, indexGen.generation)
+		if err != nil {
+			return "", err
+		}
+
+		if !instanceGuidTaken(instanceGuid, actualLRPs) {
+			indexGen.instanceGuid = instanceGuid
+			return instanceGuid, nil
+		}
+
+		indexGen.generation++
+	}
+}
+
+func (p *processor) forgetGenerations(processGuid string) {
+	p.generationsLock.Lock()
+	defer p.generationsLock.Unlock()
+
+	delete(p.generations, processGuid)
+}
+
+func instanceGuidTaken(instanceGuid string, actualLRPs []models.ActualLRP) bool {
+	for _, actual := range actualLRPs {
+		if actual.InstanceGuid == instanceGuid {
+			return true
+		}
+	}
+
+	return false
+}
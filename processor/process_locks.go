@@ -0,0 +1,48 @@
+package processor
+
+import "sync"
+
+// processLocks keep two pieces of work for the same process from running at
+// once, wherever they come from. A lock only exists while it is held or
+// waited for.
+type processLocks struct {
+	locks map[string]*processLock
+	lock  sync.Mutex
+}
+
+type processLock struct {
+	sync.Mutex
+	holders int
+}
+
+func newProcessLocks() *processLocks {
+	return &processLocks{
+		locks: map[string]*processLock{},
+	}
+}
+
+// acquire blocks until no other work holds the lock of the process, and
+// returns the function that releases it.
+func (l *processLocks) acquire(processGuid string) func() {
+	l.lock.Lock()
+	held, found := l.locks[processGuid]
+	if !found {
+		held = &processLock{}
+		l.locks[processGuid] = held
+	}
+	held.holders++
+	l.lock.Unlock()
+
+	held.Lock()
+
+	return func() {
+		held.Unlock()
+
+		l.lock.Lock()
+		held.holders--
+		if held.holders == 0 {
+			delete(l.locks, processGuid)
+		}
+		l.lock.Unlock()
+	}
+}
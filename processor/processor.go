@@ -0,0 +1,585 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/auctions"
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	"github.com/cloudfoundry-incubator/app-manager/freshness"
+	"github.com/cloudfoundry-incubator/app-manager/instanceguid"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	"github.com/cloudfoundry-incubator/app-manager/reconciler"
+	"github.com/cloudfoundry-incubator/app-manager/restartpolicy"
+	"github.com/cloudfoundry-incubator/app-manager/stackcache"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/app-manager/validation"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/pivotal-golang/lager"
+)
+
+var ErrNoHealthCheckDefined = errors.New("no health check defined for stack")
+var ErrInvalidDesiredLRP = errors.New("desired LRP is invalid")
+var ErrStartThrottled = errors.New("start throttled")
+
+type LRPreProcessor interface {
+	PreProcess(lrp models.DesiredLRP, instanceIndex int, instanceGuid string) (models.DesiredLRP, error)
+}
+
+// A Processor brings the actual LRPs of a process in line with what is
+// desired of it. It fetches them itself, once no other work for the process
+// is running, so that it never acts on actuals that another piece of work
+// is about to change.
+//
+// ProcessDesiredChange returns an error if the change could not be brought
+// about in full, so that it is not taken to have been processed.
+type Processor interface {
+	ProcessDesiredChange(desiredChange models.DesiredLRPChange) error
+	Reconcile(desiredLRP models.DesiredLRP, desiredInstances int)
+}
+
+type processor struct {
+	bbs             Bbs.AppManagerBBS
+	auctionBBS      auctions.AuctionBBS
+	rollbackBBS     RollbackBBS
+	lrPreProcessor  LRPreProcessor
+	validator       validation.Validator
+	instanceGuids   instanceguid.Generator
+	desiredIndexBBS desiredwatch.DesiredIndexBBS
+	reconciler      reconciler.Reconciler
+	updateStrategy  UpdateStrategy
+	scaleDownLimits ScaleDownLimits
+	startThrottle   ratelimit.Throttle
+	freshnessBBS    freshness.FreshnessBBS
+	stackHolder     stackcache.Holder
+	restartPolicy   restartpolicy.RestartPolicy
+	timeProvider    timeprovider.TimeProvider
+	registry        status.Registry
+	emitter         metrics.Emitter
+	logger          lager.Logger
+
+	updates     map[string]*rollingUpdate
+	seen        map[string]bool
+	updatesLock sync.Mutex
+
+	domains     map[string]string
+	domainsLock sync.Mutex
+
+	generations     map[string]*processGenerations
+	generationsLock sync.Mutex
+
+	processLocks *processLocks
+}
+
+// Config holds what a processor can do without. Its zero value neither rolls
+// updates out nor limits stops, and each collaborator left nil turns off what
+// it is there for.
+type Config struct {
+	UpdateStrategy  UpdateStrategy
+	ScaleDownLimits ScaleDownLimits
+
+	// RollbackBBS writes the spec an update replaced back once the update
+	// is to be rolled back.
+	RollbackBBS RollbackBBS
+
+	// DesiredIndexBBS looks up the modified index of a desired LRP, which
+	// instance guids are derived from along with the generation.
+	DesiredIndexBBS desiredwatch.DesiredIndexBBS
+
+	// StartThrottle is asked before every start is requested.
+	StartThrottle ratelimit.Throttle
+
+	// FreshnessBBS holds back the stops for a removed process whose domain
+	// is stale.
+	FreshnessBBS freshness.FreshnessBBS
+
+	// StackHolder holds back desired LRPs for stacks no executor runs.
+	StackHolder stackcache.Holder
+}
+
+func New(
+	bbs Bbs.AppManagerBBS,
+	auctionBBS auctions.AuctionBBS,
+	lrPreProcessor LRPreProcessor,
+	validator validation.Validator,
+	instanceGuids instanceguid.Generator,
+	reconciler reconciler.Reconciler,
+	restartPolicy restartpolicy.RestartPolicy,
+	config Config,
+	timeProvider timeprovider.TimeProvider,
+	registry status.Registry,
+	emitter metrics.Emitter,
+	logger lager.Logger,
+) Processor {
+	return &processor{
+		bbs:             bbs,
+		auctionBBS:      auctionBBS,
+		rollbackBBS:     config.RollbackBBS,
+		lrPreProcessor:  lrPreProcessor,
+		validator:       validator,
+		instanceGuids:   instanceGuids,
+		desiredIndexBBS: config.DesiredIndexBBS,
+		reconciler:      reconciler,
+		updateStrategy:  config.UpdateStrategy,
+		scaleDownLimits: config.ScaleDownLimits,
+		startThrottle:   config.StartThrottle,
+		freshnessBBS:    config.FreshnessBBS,
+		stackHolder:     config.StackHolder,
+		restartPolicy:   restartPolicy,
+		timeProvider:    timeProvider,
+		registry:        registry,
+		emitter:         emitter,
+		logger:          logger.Session("processor"),
+
+		updates: map[string]*rollingUpdate{},
+		seen:    map[string]bool{},
+		domains: map[string]string{},
+
+		generations: map[string]*processGenerations{},
+
+		processLocks: newProcessLocks(),
+	}
+}
+
+func (p *processor) ProcessDesiredChange(desiredChange models.DesiredLRPChange) error {
+	var desiredLRP models.DesiredLRP
+	var desiredInstances int
+
+	changeLogger := p.logger.Session("desired-lrp-change")
+
+	if desiredChange.After == nil {
+		desiredLRP = *desiredChange.Before
+		desiredInstances = 0
+	} else {
+		desiredLRP = *desiredChange.After
+		desiredInstances = desiredLRP.Instances
+	}
+
+	defer p.processLocks.acquire(desiredLRP.ProcessGuid)()
+	defer p.observeDuration(metrics.DesiredChangeDuration, time.Now())
+
+	removed := desiredChange.After == nil
+
+	actualLRPs, err := p.fetchActuals(changeLogger, desiredLRP, desiredInstances, removed)
+	if err != nil {
+		return err
+	}
+
+	var update *rollingUpdate
+	if !removed {
+		before := desiredLRP
+		if desiredChange.Before != nil {
+			before = *desiredChange.Before
+		}
+
+		update = p.updateFor(changeLogger, before, desiredLRP, actualLRPs)
+	}
+
+	return p.process(changeLogger, desiredLRP, desiredInstances, removed, actualLRPs, update)
+}
+
+func (p *processor) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int) {
+	reconcileLogger := p.logger.Session("reconcile")
+
+	defer p.processLocks.acquire(desiredLRP.ProcessGuid)()
+	defer p.observeDuration(metrics.ReconcileDuration, time.Now())
+
+	actualLRPs, err := p.fetchActuals(reconcileLogger, desiredLRP, desiredInstances, false)
+	if err != nil {
+		return
+	}
+
+	var update *rollingUpdate
+	if desiredInstances > 0 {
+		update = p.updateFor(reconcileLogger, desiredLRP, desiredLRP, actualLRPs)
+	}
+
+	p.process(reconcileLogger, desiredLRP, desiredInstances, false, actualLRPs, update)
+}
+
+func (p *processor) fetchActuals(logger lager.Logger, desiredLRP models.DesiredLRP, desiredInstances int, removed bool) ([]models.ActualLRP, error) {
+	actualLRPs, err := p.bbs.GetActualLRPsByProcessGuid(desiredLRP.ProcessGuid)
+	if err != nil {
+		logger.Error("fetch-actuals-failed", err, lager.Data{"desired-app-message": desiredLRP})
+		p.recordStatus(&status.ProcessStatus{
+			ProcessGuid:      desiredLRP.ProcessGuid,
+			DesiredInstances: desiredInstances,
+			LastError:        err.Error(),
+		}, removed)
+		return nil, err
+	}
+
+	return actualLRPs, nil
+}
+
+// updateFor returns the rolling update the process should be driven by, if
+// any, beginning a new one when before and after differ in spec. Processes
+// that go away take their update with them.
+func (p *processor) updateFor(logger lager.Logger, before, after models.DesiredLRP, actualLRPs []models.ActualLRP) *rollingUpdate {
+	if !p.updateStrategy.Rolling {
+		return nil
+	}
+
+	update := p.activeUpdate(after.ProcessGuid)
+	if update != nil {
+		if update.retarget(after) {
+			return update
+		}
+
+		// Reconciling does not know the spec it is replacing, but the update
+		// it interrupts does.
+		if !specChanged(before, after) {
+			before = update.target()
+		}
+
+		return p.beginUpdate(logger, before, after, actualLRPs)
+	}
+
+	firstSight := p.firstSight(after.ProcessGuid)
+
+	if specChanged(before, after) {
+		return p.beginUpdate(logger, before, after, actualLRPs)
+	}
+
+	if firstSight && mayBeInterrupted(logger, after, actualLRPs) {
+		return p.beginUpdate(logger, after, after, actualLRPs)
+	}
+
+	return nil
+}
+
+// process brings the actual LRPs of a process in line with desiredInstances.
+// removed is set when the desired LRP itself has gone away, rather than
+// having been scaled down to no instances.
+//
+// Refusing an invalid desired LRP, holding back an unschedulable one and
+// leaving stops for a later reconcile are deliberate, and not errors.
+func (p *processor) process(logger lager.Logger, desiredLRP models.DesiredLRP, desiredInstances int, removed bool, actualLRPs []models.ActualLRP, update *rollingUpdate) error {
+	report := &status.ProcessStatus{
+		ProcessGuid:      desiredLRP.ProcessGuid,
+		DesiredInstances: desiredInstances,
+		ActualInstances:  len(actualLRPs),
+		Updating:         update != nil,
+	}
+
+	defer p.recordStatus(report, removed)
+
+	if removed {
+		p.restartPolicy.Forget(desiredLRP.ProcessGuid)
+		p.forgetGenerations(desiredLRP.ProcessGuid)
+		p.forgetSeen(desiredLRP.ProcessGuid)
+	}
+
+	if desiredInstances > 0 {
+		err := p.observeDesiredIndex(logger, report, desiredLRP)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.observeGenerations(desiredLRP.ProcessGuid, actualLRPs)
+
+	domain := p.rememberDomain(desiredLRP, desiredInstances, actualLRPs)
+
+	if !removed && p.refuseInvalid(logger, report, desiredLRP) {
+		p.endUpdate(desiredLRP.ProcessGuid, p.activeUpdate(desiredLRP.ProcessGuid))
+		return nil
+	}
+
+	if p.holdUnschedulable(logger, report, desiredLRP, desiredInstances) {
+		return nil
+	}
+
+	inFlight, err := p.fetchAuctionsInFlight(desiredLRP.ProcessGuid)
+	if err != nil {
+		logger.Error("fetch-auctions-failed", err, lager.Data{"desired-app-message": desiredLRP})
+		report.LastError = err.Error()
+		return err
+	}
+
+	if update == nil {
+		p.endUpdate(desiredLRP.ProcessGuid, p.activeUpdate(desiredLRP.ProcessGuid))
+
+		mayReconcile := !removed || len(actualLRPs) == 0
+		if !mayReconcile {
+			mayReconcile, err = p.mayStopAll(logger, report, desiredLRP, domain)
+			if err != nil {
+				return err
+			}
+		}
+
+		if mayReconcile {
+			p.reconcile(logger, report, desiredLRP, desiredInstances, actualLRPs, inFlight)
+		}
+	} else if p.stepUpdate(logger, report, update, actualLRPs, inFlight) {
+		p.endUpdate(desiredLRP.ProcessGuid, update)
+		p.recordRollout(update, status.RolloutCompleted, "")
+	} else if reason := p.rollbackReason(update); reason != "" {
+		err := p.rollBack(logger, report, update, actualLRPs, reason)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		err := fmt.Errorf("%d starts or stops could not be requested", len(report.Failures))
+		logger.Error("reconcile-incomplete", err, lager.Data{
+			"desired-app-message": desiredLRP,
+			"failures":            report.Failures,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// recordFailure notes a start or stop that could not be requested, so that
+// the failures of a pass can be reported together once it is done.
+func (p *processor) recordFailure(report *status.ProcessStatus, failure status.Failure) {
+	report.Failures = append(report.Failures, failure)
+	report.LastError = failure.Error
+}
+
+// Processes that have been removed are forgotten, so that the registry does
+// not keep growing with every process that ever ran. Processes scaled to no
+// instances are still desired, and keep their history.
+func (p *processor) recordStatus(report *status.ProcessStatus, removed bool) {
+	if removed {
+		p.registry.RemoveProcess(report.ProcessGuid)
+		return
+	}
+
+	p.registry.RecordProcess(*report)
+}
+
+func (p *processor) reconcile(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP, inFlight auctionsInFlight) {
+	instanceGuidToActual := actualsByInstanceGuid(actualLRPs)
+
+	delta := p.reconciler.Reconcile(desiredLRP, desiredInstances, actualLRPs)
+	report.LastReconcile = delta
+
+	for _, lrpIndex := range delta.IndicesToStart {
+		if p.startInFlight(logger, report, desiredLRP, inFlight, lrpIndex) {
+			continue
+		}
+
+		if !p.restartAllowed(logger, report, desiredLRP, lrpIndex) {
+			continue
+		}
+
+		p.startInstance(logger, report, desiredLRP, lrpIndex, actualLRPs)
+	}
+
+	actualsToStop := []models.ActualLRP{}
+	for _, guidToStop := range delta.GuidsToStop {
+		actualsToStop = append(actualsToStop, instanceGuidToActual[guidToStop])
+	}
+
+	for _, actualToStop := range p.limitStops(logger, desiredLRP, actualsToStop) {
+		// Stopping a duplicate leaves its index running, so only indices that
+		// are no longer desired are stopped as far as restarts are concerned.
+		err := p.stopInstance(logger, report, desiredLRP, actualToStop)
+		if err == nil && actualToStop.Index >= desiredInstances {
+			p.restartPolicy.RecordStop(actualToStop.ProcessGuid, actualToStop.Index)
+		}
+	}
+
+	for _, indexToStopAllButOne := range delta.IndicesToStopAllButOne {
+		if p.stopAuctionInFlight(logger, desiredLRP, inFlight, indexToStopAllButOne) {
+			continue
+		}
+
+		logger.Info("request-stop-auction", lager.Data{
+			"desired-app-message":  desiredLRP,
+			"stop-duplicate-index": indexToStopAllButOne,
+		})
+		err := p.bbs.RequestLRPStopAuction(models.LRPStopAuction{
+			ProcessGuid: desiredLRP.ProcessGuid,
+			Index:       indexToStopAllButOne,
+		})
+
+		if err != nil {
+			logger.Error("request-stop-auction-failed", err, lager.Data{
+				"desired-app-message":  desiredLRP,
+				"stop-duplicate-index": indexToStopAllButOne,
+			})
+			p.recordFailure(report, status.Failure{
+				Action: status.StopAuctionAction,
+				Index:  indexToStopAllButOne,
+				Error:  err.Error(),
+			})
+			p.emitter.IncrementCounter(metrics.BBSWriteFailures)
+		} else {
+			report.StopAuctions = append(report.StopAuctions, indexToStopAllButOne)
+			p.emitter.IncrementCounter(metrics.StopAuctionsRequested)
+		}
+	}
+}
+
+// restartAllowed consults the restart policy before an index is started, in
+// case the instance that was last started there has crashed.
+func (p *processor) restartAllowed(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, lrpIndex int) bool {
+	switch p.restartPolicy.Decide(desiredLRP.ProcessGuid, lrpIndex) {
+	case restartpolicy.BackOff:
+		logger.Info("backing-off-restart", lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+		})
+		report.BackingOff = append(report.BackingOff, lrpIndex)
+		p.emitter.IncrementCounter(metrics.RestartsBackedOff)
+		return false
+
+	case restartpolicy.GiveUp:
+		logger.Info("gave-up-restarting", lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+		})
+		report.GivenUp = append(report.GivenUp, lrpIndex)
+		p.emitter.IncrementCounter(metrics.RestartsGivenUp)
+		return false
+	}
+
+	return true
+}
+
+// startInstance only returns an error when the start throttle refused it or
+// the instance could not be described; failing to write the auction is
+// recorded and otherwise ignored. An auction that already exists for the
+// index is taken to be an earlier request for the same start.
+//
+// A throttled start is left for the reconcile that follows once the throttle
+// lets the process go ahead again.
+func (p *processor) startInstance(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, lrpIndex int, actualLRPs []models.ActualLRP) (string, error) {
+	if p.startThrottle != nil && !p.startThrottle.Take(desiredLRP.ProcessGuid) {
+		logger.Info("throttling-start", lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+		})
+		report.StartsThrottled = append(report.StartsThrottled, lrpIndex)
+		p.emitter.IncrementCounter(metrics.StartsThrottled)
+		return "", ErrStartThrottled
+	}
+
+	logger.Info("request-start", lager.Data{
+		"desired-app-message": desiredLRP,
+		"index":               lrpIndex,
+	})
+
+	instanceGuid, err := p.nextInstanceGuid(desiredLRP.ProcessGuid, lrpIndex, actualLRPs)
+	if err != nil {
+		logger.Error("generating-instance-guid-failed", err)
+		p.recordFailure(report, status.Failure{
+			Action: status.StartAction,
+			Index:  lrpIndex,
+			Error:  err.Error(),
+		})
+		return "", err
+	}
+
+	preprocessedLRP, err := p.lrPreProcessor.PreProcess(desiredLRP, lrpIndex, instanceGuid)
+	if err != nil {
+		logger.Error("failed-to-preprocess-lrp", err, lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+		})
+		p.recordFailure(report, status.Failure{
+			Action:       status.StartAction,
+			Index:        lrpIndex,
+			InstanceGuid: instanceGuid,
+			Error:        err.Error(),
+		})
+		p.emitter.IncrementCounter(metrics.PreprocessFailures)
+		return "", err
+	}
+
+	startMessage := models.LRPStartAuction{
+		DesiredLRP: preprocessedLRP,
+
+		Index:        lrpIndex,
+		InstanceGuid: instanceGuid,
+	}
+
+	err = p.bbs.RequestLRPStartAuction(startMessage)
+
+	if err == storeadapter.ErrorKeyExists {
+		logger.Info("start-already-requested", lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+			"instance-guid":       instanceGuid,
+		})
+		p.emitter.IncrementCounter(metrics.InFlightSkips)
+	} else if err != nil {
+		logger.Error("request-start-auction-failed", err, lager.Data{
+			"desired-app-message": desiredLRP,
+			"index":               lrpIndex,
+		})
+		p.recordFailure(report, status.Failure{
+			Action:       status.StartAction,
+			Index:        lrpIndex,
+			InstanceGuid: instanceGuid,
+			Error:        err.Error(),
+		})
+		p.emitter.IncrementCounter(metrics.BBSWriteFailures)
+	} else {
+		report.StartAuctions = append(report.StartAuctions, status.StartAuction{
+			Index:        lrpIndex,
+			InstanceGuid: instanceGuid,
+		})
+		p.emitter.IncrementCounter(metrics.StartsRequested)
+		p.restartPolicy.RecordStart(desiredLRP.ProcessGuid, lrpIndex)
+	}
+
+	return instanceGuid, nil
+}
+
+func (p *processor) stopInstance(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, actualToStop models.ActualLRP) error {
+	logger.Info("request-stop-instance", lager.Data{
+		"desired-app-message": desiredLRP,
+		"stop-instance-guid":  actualToStop.InstanceGuid,
+	})
+
+	err := p.bbs.RequestStopLRPInstance(models.StopLRPInstance{
+		ProcessGuid:  actualToStop.ProcessGuid,
+		InstanceGuid: actualToStop.InstanceGuid,
+		Index:        actualToStop.Index,
+	})
+
+	if err != nil {
+		logger.Error("request-stop-instance-failed", err, lager.Data{
+			"desired-app-message": desiredLRP,
+			"stop-instance-guid":  actualToStop.InstanceGuid,
+		})
+		p.recordFailure(report, status.Failure{
+			Action:       status.StopInstanceAction,
+			Index:        actualToStop.Index,
+			InstanceGuid: actualToStop.InstanceGuid,
+			Error:        err.Error(),
+		})
+		p.emitter.IncrementCounter(metrics.BBSWriteFailures)
+	} else {
+		report.StoppedInstances = append(report.StoppedInstances, actualToStop.InstanceGuid)
+		p.emitter.IncrementCounter(metrics.StopInstancesRequested)
+	}
+
+	return err
+}
+
+func (p *processor) observeDuration(name string, startedAt time.Time) {
+	p.emitter.ObserveDuration(name, time.Since(startedAt))
+}
+
+func actualsByInstanceGuid(actualLRPs []models.ActualLRP) map[string]models.ActualLRP {
+	instanceGuidToActual := map[string]models.ActualLRP{}
+
+	for _, actualLRP := range actualLRPs {
+		instanceGuidToActual[actualLRP.InstanceGuid] = actualLRP
+	}
+
+	return instanceGuidToActual
+}
@@ -0,0 +1,13 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestProcessor(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Processor Suite")
+}
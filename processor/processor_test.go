@@ -0,0 +1,1897 @@
+package processor_test
+
+import (
+	"errors"
+	"time"
+
+	auctions_fakes "github.com/cloudfoundry-incubator/app-manager/auctions/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/desiredwatch"
+	desiredwatch_fakes "github.com/cloudfoundry-incubator/app-manager/desiredwatch/fakes"
+	freshness_fakes "github.com/cloudfoundry-incubator/app-manager/freshness/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/instanceguid"
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	metrics_fakes "github.com/cloudfoundry-incubator/app-manager/metrics/fakes"
+	. "github.com/cloudfoundry-incubator/app-manager/processor"
+	"github.com/cloudfoundry-incubator/app-manager/processor/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	ratelimit_fakes "github.com/cloudfoundry-incubator/app-manager/ratelimit/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/reconciler"
+	"github.com/cloudfoundry-incubator/app-manager/restartpolicy"
+	restartpolicy_fakes "github.com/cloudfoundry-incubator/app-manager/restartpolicy/fakes"
+	stackcache_fakes "github.com/cloudfoundry-incubator/app-manager/stackcache/fakes"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/app-manager/validation"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fake_bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Processor", func() {
+	var (
+		bbs         *fake_bbs.FakeAppManagerBBS
+		auctionBBS  *auctions_fakes.FakeAuctionBBS
+		rollbackBBS *fakes.FakeRollbackBBS
+		lrpp        *fakes.FakeLRPreProcessor
+		logger      *lagertest.TestLogger
+		desiredLRP  models.DesiredLRP
+
+		validator       validation.Validator
+		instanceGuids   instanceguid.Generator
+		desiredIndexBBS desiredwatch.DesiredIndexBBS
+		lrpReconciler   reconciler.Reconciler
+		updateStrategy  UpdateStrategy
+		scaleDownLimits ScaleDownLimits
+		startThrottle   *ratelimit_fakes.FakeThrottle
+		freshnessBBS    *freshness_fakes.FakeFreshnessBBS
+		stackHolder     *stackcache_fakes.FakeHolder
+		restartPolicy   *restartpolicy_fakes.FakeRestartPolicy
+		timeProvider    *faketimeprovider.FakeTimeProvider
+		registry        status.Registry
+		emitter         *metrics_fakes.FakeEmitter
+		processor       Processor
+	)
+
+	BeforeEach(func() {
+		bbs = fake_bbs.NewFakeAppManagerBBS()
+		auctionBBS = new(auctions_fakes.FakeAuctionBBS)
+		rollbackBBS = new(fakes.FakeRollbackBBS)
+
+		logger = lagertest.NewTestLogger("test")
+
+		validator = validation.New()
+		instanceGuids = instanceguid.NewRandom()
+		desiredIndexBBS = nil
+		lrpReconciler = reconciler.NewDeltaForce()
+		updateStrategy = UpdateStrategy{}
+		scaleDownLimits = ScaleDownLimits{}
+		startThrottle = new(ratelimit_fakes.FakeThrottle)
+		startThrottle.TakeReturns(true)
+		freshnessBBS = new(freshness_fakes.FakeFreshnessBBS)
+		freshnessBBS.IsFreshReturns(true, nil)
+		stackHolder = new(stackcache_fakes.FakeHolder)
+		restartPolicy = new(restartpolicy_fakes.FakeRestartPolicy)
+		timeProvider = faketimeprovider.New(time.Unix(1000, 0))
+		registry = status.NewRegistry()
+		emitter = metrics_fakes.NewFakeEmitter()
+
+		lrpp = new(fakes.FakeLRPreProcessor)
+		lrpp.PreProcessStub = func(lrp models.DesiredLRP, index int, guid string) (models.DesiredLRP, error) {
+			return lrp, nil
+		}
+
+	})
+
+	newProcessor := func() Processor {
+		return New(bbs, auctionBBS, lrpp, validator, instanceGuids, lrpReconciler, restartPolicy, Config{
+			UpdateStrategy:  updateStrategy,
+			ScaleDownLimits: scaleDownLimits,
+			RollbackBBS:     rollbackBBS,
+			DesiredIndexBBS: desiredIndexBBS,
+			StartThrottle:   startThrottle,
+			FreshnessBBS:    freshnessBBS,
+			StackHolder:     stackHolder,
+		}, timeProvider, registry, emitter, logger)
+	}
+
+	JustBeforeEach(func() {
+		processor = newProcessor()
+	})
+
+	reconcileAgainst := func(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) {
+		bbs.ActualLRPs = actualLRPs
+		processor.Reconcile(desiredLRP, desiredInstances)
+	}
+
+	BeforeEach(func() {
+		desiredLRP = models.DesiredLRP{
+			ProcessGuid: "the-app-guid-the-app-version",
+			Domain:      "some-domain",
+
+			Instances: 2,
+			Stack:     "some-stack",
+			MemoryMB:  128,
+
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.RunAction{
+						Path: "some-run-action-path",
+					},
+				},
+			},
+		}
+	})
+
+	Describe("processing a desired LRP change", func() {
+		var processErr error
+
+		JustBeforeEach(func() {
+			processErr = processor.ProcessDesiredChange(models.DesiredLRPChange{
+				Before: nil,
+				After:  &desiredLRP,
+			})
+		})
+
+		Describe("the happy path", func() {
+			BeforeEach(func() {
+				lrpp.PreProcessStub = func(lrp models.DesiredLRP, index int, guid string) (models.DesiredLRP, error) {
+					lrp.ProcessGuid = "preprocessed-" + lrp.ProcessGuid
+					return lrp, nil
+				}
+			})
+
+			It("puts a LRPStartAuction in the bbs with a preprocessed LRP", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(2))
+
+				firstStartAuction := startAuctions[0]
+				Ω(firstStartAuction.DesiredLRP.ProcessGuid).Should(Equal("preprocessed-the-app-guid-the-app-version"))
+				Ω(firstStartAuction.InstanceGuid).ShouldNot(BeEmpty())
+
+				secondStartAuction := startAuctions[1]
+				Ω(secondStartAuction.DesiredLRP.ProcessGuid).Should(Equal("preprocessed-the-app-guid-the-app-version"))
+				Ω(secondStartAuction.InstanceGuid).ShouldNot(BeEmpty())
+
+				Ω(firstStartAuction.InstanceGuid).ShouldNot(Equal(secondStartAuction.InstanceGuid))
+			})
+
+			It("reports the change as processed", func() {
+				Ω(processErr).ShouldNot(HaveOccurred())
+			})
+
+			It("counts the starts it requested", func() {
+				Ω(emitter.Counter(metrics.StartsRequested)).Should(Equal(2))
+			})
+
+			It("observes how long processing the change took", func() {
+				Ω(emitter.Durations(metrics.DesiredChangeDuration)).Should(HaveLen(1))
+			})
+
+			It("records what it did for the process", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(processStatus.DesiredInstances).Should(Equal(2))
+				Ω(processStatus.ActualInstances).Should(Equal(0))
+				Ω(processStatus.LastReconcile.IndicesToStart).Should(Equal([]int{0, 1}))
+				Ω(processStatus.StartAuctions).Should(Equal([]status.StartAuction{
+					{Index: 0, InstanceGuid: startAuctions[0].InstanceGuid},
+					{Index: 1, InstanceGuid: startAuctions[1].InstanceGuid},
+				}))
+				Ω(processStatus.LastError).Should(BeEmpty())
+			})
+
+			It("records the starts with the restart policy", func() {
+				Ω(restartPolicy.RecordStartCallCount()).Should(Equal(2))
+
+				processGuid, index := restartPolicy.RecordStartArgsForCall(1)
+				Ω(processGuid).Should(Equal("the-app-guid-the-app-version"))
+				Ω(index).Should(Equal(1))
+			})
+
+			It("assigns increasing indices for the auction requests", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(2))
+
+				firstStartAuction := startAuctions[0]
+				secondStartAuction := startAuctions[1]
+
+				Ω(firstStartAuction.Index).Should(Equal(0))
+				Ω(secondStartAuction.Index).Should(Equal(1))
+			})
+		})
+
+		Context("when the restart policy backs off an index", func() {
+			BeforeEach(func() {
+				restartPolicy.DecideStub = func(processGuid string, index int) restartpolicy.Decision {
+					if index == 0 {
+						return restartpolicy.BackOff
+					}
+					return restartpolicy.Start
+				}
+			})
+
+			It("starts only the other indices", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(1))
+				Ω(startAuctions[0].Index).Should(Equal(1))
+			})
+
+			It("records that the index is backing off", func() {
+				processStatus, _ := registry.Process("the-app-guid-the-app-version")
+				Ω(processStatus.BackingOff).Should(Equal([]int{0}))
+				Ω(emitter.Counter(metrics.RestartsBackedOff)).Should(Equal(1))
+			})
+		})
+
+		Context("when the restart policy has given up on an index", func() {
+			BeforeEach(func() {
+				restartPolicy.DecideReturns(restartpolicy.GiveUp)
+			})
+
+			It("does not start it", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("records that it gave up", func() {
+				processStatus, _ := registry.Process("the-app-guid-the-app-version")
+				Ω(processStatus.GivenUp).Should(Equal([]int{0, 1}))
+				Ω(emitter.Counter(metrics.RestartsGivenUp)).Should(Equal(2))
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("gave-up-restarting"))
+			})
+		})
+
+		Context("when preprocessing fails", func() {
+			BeforeEach(func() {
+				lrpp.PreProcessStub = nil
+				lrpp.PreProcessReturns(models.DesiredLRP{}, errors.New("oh no!"))
+			})
+
+			It("does not put a LRPStartAuction in the bbs", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("counts the failures", func() {
+				Ω(emitter.Counter(metrics.PreprocessFailures)).Should(Equal(2))
+			})
+		})
+
+		Context("when preprocessing fails for one index", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = 3
+
+				lrpp.PreProcessStub = func(lrp models.DesiredLRP, index int, guid string) (models.DesiredLRP, error) {
+					if index == 1 {
+						return models.DesiredLRP{}, errors.New("oh no!")
+					}
+
+					return lrp, nil
+				}
+			})
+
+			It("still starts the other indices", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(2))
+				Ω(startAuctions[0].Index).Should(Equal(0))
+				Ω(startAuctions[1].Index).Should(Equal(2))
+			})
+
+			It("records the failure for the process", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.Failures).Should(HaveLen(1))
+				Ω(processStatus.Failures[0].Action).Should(Equal(status.StartAction))
+				Ω(processStatus.Failures[0].Index).Should(Equal(1))
+				Ω(processStatus.Failures[0].Error).Should(Equal("oh no!"))
+				Ω(processStatus.LastError).Should(Equal("oh no!"))
+			})
+
+			It("logs that the reconcile was incomplete", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.reconcile-incomplete"))
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say(`"index":1`))
+			})
+
+			It("reports the change as not processed in full", func() {
+				Ω(processErr).Should(MatchError("1 starts or stops could not be requested"))
+			})
+		})
+
+		It("asks the start throttle before requesting each start", func() {
+			Ω(startThrottle.TakeCallCount()).Should(Equal(2))
+			Ω(startThrottle.TakeArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+		})
+
+		Context("when the start throttle refuses some of the starts", func() {
+			BeforeEach(func() {
+				taken := 0
+				startThrottle.TakeStub = func(string) bool {
+					taken++
+					return taken == 1
+				}
+			})
+
+			It("only requests the starts it let go ahead", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(1))
+				Ω(startAuctions[0].Index).Should(Equal(0))
+			})
+
+			It("does not describe the throttled instances", func() {
+				Ω(lrpp.PreProcessCallCount()).Should(Equal(1))
+			})
+
+			It("records and counts the throttled starts, without failing them", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.StartsThrottled).Should(Equal([]int{1}))
+				Ω(processStatus.Failures).Should(BeEmpty())
+				Ω(emitter.Counter(metrics.StartsThrottled)).Should(Equal(1))
+				Ω(processErr).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when there is an error writing a LRPStartAuction to the BBS", func() {
+			BeforeEach(func() {
+				bbs.LRPStartAuctionErr = errors.New("connection error")
+			})
+
+			It("logs an error", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.request-start-auction-failed"))
+			})
+
+			It("counts the failed writes", func() {
+				Ω(emitter.Counter(metrics.BBSWriteFailures)).Should(Equal(2))
+				Ω(emitter.Counter(metrics.StartsRequested)).Should(BeZero())
+			})
+
+			It("records the error for the process", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.LastError).Should(Equal("connection error"))
+				Ω(processStatus.StartAuctions).Should(BeEmpty())
+			})
+		})
+
+		Context("when there is an error fetching the auctions in flight", func() {
+			BeforeEach(func() {
+				auctionBBS.GetLRPStartAuctionsByProcessGuidReturns(nil, errors.New("connection error"))
+			})
+
+			It("does not put a LRPStartAuction in the bbs", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("logs an error", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.fetch-auctions-failed"))
+			})
+
+			It("returns the error", func() {
+				Ω(processErr).Should(MatchError("connection error"))
+			})
+		})
+
+		Context("when there is an error fetching the actual instances", func() {
+			BeforeEach(func() {
+				bbs.ActualLRPsErr = errors.New("connection error")
+			})
+
+			It("does not put a LRPStartAuction in the bbs", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("logs an error", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.fetch-actuals-failed"))
+			})
+
+			It("returns the error", func() {
+				Ω(processErr).Should(MatchError("connection error"))
+			})
+		})
+
+		Context("when there are already instances running for the desired app, but some are missing", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = 4
+				bbs.ActualLRPs = []models.ActualLRP{
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "a",
+						Index:        0,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "b",
+						Index:        4,
+						State:        models.ActualLRPStateRunning,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "c",
+						Index:        5,
+						State:        models.ActualLRPStateRunning,
+					},
+				}
+			})
+
+			It("only starts missing ones", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(3))
+
+				Ω(startAuctions[0].Index).Should(Equal(1))
+				Ω(startAuctions[1].Index).Should(Equal(2))
+				Ω(startAuctions[2].Index).Should(Equal(3))
+			})
+
+			It("does not stop extra ones", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+			})
+
+			Context("when some of the missing ones are already being auctioned", func() {
+				BeforeEach(func() {
+					auctionBBS.GetLRPStartAuctionsByProcessGuidReturns([]models.LRPStartAuction{
+						{DesiredLRP: desiredLRP, Index: 1, InstanceGuid: "pending", State: models.LRPStartAuctionStatePending},
+						{DesiredLRP: desiredLRP, Index: 3, InstanceGuid: "claimed", State: models.LRPStartAuctionStateClaimed},
+					}, nil)
+				})
+
+				It("only fetches the auctions of the process", func() {
+					Ω(auctionBBS.GetLRPStartAuctionsByProcessGuidArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+					Ω(auctionBBS.GetLRPStopAuctionsByProcessGuidArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+				})
+
+				It("only starts the ones that are not", func() {
+					startAuctions := bbs.GetLRPStartAuctions()
+					Ω(startAuctions).Should(HaveLen(1))
+					Ω(startAuctions[0].Index).Should(Equal(2))
+				})
+
+				It("reports, logs and counts the starts already in flight", func() {
+					processStatus, ok := registry.Process("the-app-guid-the-app-version")
+					Ω(ok).Should(BeTrue())
+					Ω(processStatus.StartsInFlight).Should(Equal([]int{1, 3}))
+
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.start-already-in-flight"))
+					Ω(emitter.Counter(metrics.InFlightSkips)).Should(Equal(2))
+				})
+
+				It("does not count them against the restart policy", func() {
+					Ω(restartPolicy.DecideCallCount()).Should(Equal(1))
+				})
+			})
+
+			Context("with a reconciler that converges both ways at once", func() {
+				BeforeEach(func() {
+					lrpReconciler = reconciler.NewConverge()
+				})
+
+				It("starts the missing ones and stops the extra ones, highest index first", func() {
+					Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(3))
+
+					stopInstances := bbs.GetStopLRPInstances()
+					Ω(stopInstances).Should(HaveLen(2))
+					Ω(stopInstances[0].InstanceGuid).Should(Equal("c"))
+					Ω(stopInstances[1].InstanceGuid).Should(Equal("b"))
+				})
+			})
+		})
+
+		Context("when there are extra instances running for the desired app", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = 2
+				bbs.ActualLRPs = []models.ActualLRP{
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "a",
+						Index:        0,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "b",
+						Index:        1,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "c",
+						Index:        2,
+						State:        models.ActualLRPStateRunning,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "d",
+						Index:        3,
+						State:        models.ActualLRPStateRunning,
+					},
+				}
+			})
+
+			It("doesn't start anything", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("tells the restart policy the indices were stopped on purpose", func() {
+				Ω(restartPolicy.RecordStopCallCount()).Should(Equal(2))
+
+				_, firstIndex := restartPolicy.RecordStopArgsForCall(0)
+				_, secondIndex := restartPolicy.RecordStopArgsForCall(1)
+				Ω([]int{firstIndex, secondIndex}).Should(ConsistOf(2, 3))
+			})
+
+			It("stops extra ones", func() {
+				stopInstances := bbs.GetStopLRPInstances()
+				Ω(stopInstances).Should(HaveLen(2))
+
+				stopInstance1 := models.StopLRPInstance{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					Index:        2,
+					InstanceGuid: "c",
+				}
+				stopInstance2 := models.StopLRPInstance{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					Index:        3,
+					InstanceGuid: "d",
+				}
+
+				Ω(stopInstances).Should(ContainElement(stopInstance1))
+				Ω(stopInstances).Should(ContainElement(stopInstance2))
+			})
+
+			Context("when only so many stops are allowed per reconcile", func() {
+				BeforeEach(func() {
+					scaleDownLimits.MaxStopsPerReconcile = 1
+				})
+
+				It("stops the highest index first", func() {
+					stopInstances := bbs.GetStopLRPInstances()
+					Ω(stopInstances).Should(HaveLen(1))
+					Ω(stopInstances[0].InstanceGuid).Should(Equal("d"))
+				})
+
+				It("logs and counts the stops it deferred", func() {
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.deferring-stops"))
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say(`"deferred":1`))
+					Ω(emitter.Counter(metrics.StopsDeferred)).Should(Equal(1))
+				})
+
+				It("does not tell the restart policy the deferred index was stopped", func() {
+					Ω(restartPolicy.RecordStopCallCount()).Should(Equal(1))
+
+					_, index := restartPolicy.RecordStopArgsForCall(0)
+					Ω(index).Should(Equal(3))
+				})
+
+				Context("with a reconciler that prefers running instances", func() {
+					BeforeEach(func() {
+						lrpReconciler = reconciler.NewPreferRunning()
+
+						bbs.ActualLRPs[2].State = models.ActualLRPStateStarting
+					})
+
+					It("still stops the highest index first", func() {
+						stopInstances := bbs.GetStopLRPInstances()
+						Ω(stopInstances).Should(HaveLen(1))
+						Ω(stopInstances[0].InstanceGuid).Should(Equal("d"))
+					})
+
+					Context("when the highest index has more than one instance", func() {
+						BeforeEach(func() {
+							bbs.ActualLRPs = append(bbs.ActualLRPs, models.ActualLRP{
+								ProcessGuid:  "the-app-guid-the-app-version",
+								InstanceGuid: "e",
+								Index:        3,
+								State:        models.ActualLRPStateStarting,
+							})
+						})
+
+						It("stops the one the reconciler values least first", func() {
+							stopInstances := bbs.GetStopLRPInstances()
+							Ω(stopInstances).Should(HaveLen(1))
+							Ω(stopInstances[0].InstanceGuid).Should(Equal("e"))
+						})
+					})
+				})
+			})
+
+			Context("when stops are rate limited across processes", func() {
+				BeforeEach(func() {
+					scaleDownLimits.StopLimiter = ratelimit.New(1, 1, faketimeprovider.New(time.Unix(1000, 0)))
+				})
+
+				It("only stops as many as the limiter allows", func() {
+					stopInstances := bbs.GetStopLRPInstances()
+					Ω(stopInstances).Should(HaveLen(1))
+					Ω(stopInstances[0].InstanceGuid).Should(Equal("d"))
+				})
+			})
+
+			Context("when stopping them fails", func() {
+				BeforeEach(func() {
+					bbs.StopLRPInstanceErr = errors.New("connection error")
+				})
+
+				It("still tries to stop each of them", func() {
+					Ω(bbs.GetStopLRPInstances()).Should(HaveLen(2))
+				})
+
+				It("records a failure for each of them", func() {
+					processStatus, ok := registry.Process("the-app-guid-the-app-version")
+					Ω(ok).Should(BeTrue())
+					Ω(processStatus.Failures).Should(ConsistOf(
+						status.Failure{Action: status.StopInstanceAction, Index: 2, InstanceGuid: "c", Error: "connection error"},
+						status.Failure{Action: status.StopInstanceAction, Index: 3, InstanceGuid: "d", Error: "connection error"},
+					))
+				})
+			})
+		})
+
+		Context("when there are duplicate desired instances running for the desired app", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = 3
+				bbs.ActualLRPs = []models.ActualLRP{
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "a",
+						Index:        0,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "b",
+						Index:        1,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "c",
+						Index:        1,
+						State:        models.ActualLRPStateStarting,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "d",
+						Index:        2,
+						State:        models.ActualLRPStateRunning,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "e",
+						Index:        2,
+						State:        models.ActualLRPStateRunning,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "f",
+						Index:        3,
+						State:        models.ActualLRPStateRunning,
+					},
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: "g",
+						Index:        3,
+						State:        models.ActualLRPStateRunning,
+					},
+				}
+			})
+
+			It("doesn't start anything", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			Context("when a stop auction for one of the duplicates is already in flight", func() {
+				BeforeEach(func() {
+					auctionBBS.GetLRPStopAuctionsByProcessGuidReturns([]models.LRPStopAuction{
+						{ProcessGuid: "the-app-guid-the-app-version", Index: 1},
+					}, nil)
+				})
+
+				It("only requests the other stop auction", func() {
+					Ω(bbs.GetLRPStopAuctions()).Should(Equal([]models.LRPStopAuction{
+						{ProcessGuid: "the-app-guid-the-app-version", Index: 2},
+					}))
+				})
+
+				It("logs that it is in flight", func() {
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.stop-auction-already-in-flight"))
+				})
+			})
+
+			It("counts the stop auctions and stops it requested", func() {
+				Ω(emitter.Counter(metrics.StopAuctionsRequested)).Should(Equal(2))
+				Ω(emitter.Counter(metrics.StopInstancesRequested)).Should(Equal(2))
+			})
+
+			It("holds stop auctions for the desired duplicates", func() {
+				stopAuctions := bbs.GetLRPStopAuctions()
+				Ω(stopAuctions).Should(HaveLen(2))
+
+				Ω(stopAuctions).Should(ContainElement(models.LRPStopAuction{
+					ProcessGuid: "the-app-guid-the-app-version",
+					Index:       1,
+				}))
+
+				Ω(stopAuctions).Should(ContainElement(models.LRPStopAuction{
+					ProcessGuid: "the-app-guid-the-app-version",
+					Index:       2,
+				}))
+			})
+
+			It("stops extra ones", func() {
+				stopInstances := bbs.GetStopLRPInstances()
+				Ω(stopInstances).Should(HaveLen(2))
+
+				stopInstance1 := models.StopLRPInstance{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					Index:        3,
+					InstanceGuid: "f",
+				}
+				stopInstance2 := models.StopLRPInstance{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					Index:        3,
+					InstanceGuid: "g",
+				}
+
+				Ω(stopInstances).Should(ContainElement(stopInstance1))
+				Ω(stopInstances).Should(ContainElement(stopInstance2))
+			})
+
+			Context("with a reconciler that prefers running instances", func() {
+				BeforeEach(func() {
+					lrpReconciler = reconciler.NewPreferRunning()
+
+					bbs.ActualLRPs[1].State = models.ActualLRPStateRunning
+				})
+
+				It("stops the starting duplicate itself", func() {
+					stopInstances := bbs.GetStopLRPInstances()
+					Ω(stopInstances).Should(HaveLen(3))
+					Ω(stopInstances).Should(ContainElement(models.StopLRPInstance{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						Index:        1,
+						InstanceGuid: "c",
+					}))
+				})
+
+				It("only holds stop auctions for duplicates it cannot choose between", func() {
+					Ω(bbs.GetLRPStopAuctions()).Should(Equal([]models.LRPStopAuction{
+						{ProcessGuid: "the-app-guid-the-app-version", Index: 2},
+					}))
+				})
+
+				It("does not tell the restart policy the duplicate's index was stopped", func() {
+					for i := 0; i < restartPolicy.RecordStopCallCount(); i++ {
+						_, index := restartPolicy.RecordStopArgsForCall(i)
+						Ω(index).ShouldNot(Equal(1))
+					}
+				})
+			})
+		})
+	})
+
+	Describe("processing a deleted desired LRP", func() {
+		JustBeforeEach(func() {
+			processor.ProcessDesiredChange(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  nil,
+			})
+		})
+
+		BeforeEach(func() {
+			bbs.ActualLRPs = []models.ActualLRP{
+				{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					InstanceGuid: "a",
+					Index:        0,
+					State:        models.ActualLRPStateStarting,
+				},
+			}
+		})
+
+		It("doesn't start anything", func() {
+			Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+		})
+
+		It("forgets the crashes of the process", func() {
+			Ω(restartPolicy.ForgetCallCount()).Should(Equal(1))
+			Ω(restartPolicy.ForgetArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+		})
+
+		It("forgets the status of the process", func() {
+			registry.RecordProcess(status.ProcessStatus{ProcessGuid: "the-app-guid-the-app-version"})
+
+			processor.ProcessDesiredChange(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  nil,
+			})
+
+			_, ok := registry.Process("the-app-guid-the-app-version")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("stops all instances", func() {
+			stopInstances := bbs.GetStopLRPInstances()
+			Ω(stopInstances).Should(HaveLen(1))
+
+			stopInstance := models.StopLRPInstance{
+				ProcessGuid:  "the-app-guid-the-app-version",
+				Index:        0,
+				InstanceGuid: "a",
+			}
+
+			Ω(stopInstances).Should(ContainElement(stopInstance))
+		})
+
+		It("checks that the domain of the process is fresh", func() {
+			Ω(freshnessBBS.IsFreshCallCount()).Should(Equal(1))
+			Ω(freshnessBBS.IsFreshArgsForCall(0)).Should(Equal("some-domain"))
+		})
+
+		Context("when the domain of the process is not fresh", func() {
+			BeforeEach(func() {
+				freshnessBBS.IsFreshReturns(false, nil)
+			})
+
+			It("does not stop anything", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+			})
+
+			It("logs and counts that it skipped the stops", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.skipping-stop-stale-domain"))
+				Ω(emitter.Counter(metrics.StaleDomainSkips)).Should(Equal(1))
+			})
+		})
+
+		Context("when checking the freshness of the domain fails", func() {
+			BeforeEach(func() {
+				freshnessBBS.IsFreshReturns(false, errors.New("connection error"))
+			})
+
+			It("does not stop anything", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+			})
+
+			It("logs an error", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.checking-domain-freshness-failed"))
+			})
+		})
+
+		Context("when there are no instances left", func() {
+			BeforeEach(func() {
+				bbs.ActualLRPs = nil
+			})
+
+			It("does not check the domain", func() {
+				Ω(freshnessBBS.IsFreshCallCount()).Should(BeZero())
+			})
+		})
+
+		Context("when the domain of the process is not known", func() {
+			BeforeEach(func() {
+				desiredLRP.Domain = ""
+				freshnessBBS.IsFreshReturns(false, nil)
+			})
+
+			It("does not stop anything, as the domain cannot be shown to be fresh", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+				Ω(freshnessBBS.IsFreshCallCount()).Should(BeZero())
+			})
+
+			It("logs and counts that it skipped the stops", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.skipping-stop-unknown-domain"))
+				Ω(emitter.Counter(metrics.StaleDomainSkips)).Should(Equal(1))
+			})
+		})
+	})
+
+	Describe("scaling a desired LRP to no instances", func() {
+		var scaledLRP models.DesiredLRP
+
+		BeforeEach(func() {
+			registry.RecordRollout("the-app-guid-the-app-version", status.Rollout{
+				After:   desiredLRP,
+				Outcome: status.RolloutCompleted,
+			})
+
+			scaledLRP = desiredLRP
+			scaledLRP.Instances = 0
+		})
+
+		JustBeforeEach(func() {
+			processor.ProcessDesiredChange(models.DesiredLRPChange{
+				Before: &desiredLRP,
+				After:  &scaledLRP,
+			})
+		})
+
+		It("keeps the status of the process", func() {
+			processStatus, ok := registry.Process("the-app-guid-the-app-version")
+			Ω(ok).Should(BeTrue())
+			Ω(processStatus.DesiredInstances).Should(BeZero())
+			Ω(processStatus.Rollouts).Should(HaveLen(1))
+		})
+
+		It("does not forget the crashes of the process", func() {
+			Ω(restartPolicy.ForgetCallCount()).Should(BeZero())
+		})
+
+		Context("and back up again", func() {
+			JustBeforeEach(func() {
+				processor.ProcessDesiredChange(models.DesiredLRPChange{
+					Before: &scaledLRP,
+					After:  &desiredLRP,
+				})
+			})
+
+			It("still has the history of the process", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.DesiredInstances).Should(Equal(2))
+				Ω(processStatus.Rollouts).Should(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("reconciling a desired LRP", func() {
+		var actualLRPs []models.ActualLRP
+
+		BeforeEach(func() {
+			actualLRPs = []models.ActualLRP{
+				{
+					ProcessGuid:  "the-app-guid-the-app-version",
+					InstanceGuid: "a",
+					Index:        0,
+					State:        models.ActualLRPStateRunning,
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			reconcileAgainst(desiredLRP, desiredLRP.Instances, actualLRPs)
+		})
+
+		It("observes how long reconciling took", func() {
+			Ω(emitter.Durations(metrics.ReconcileDuration)).Should(HaveLen(1))
+		})
+
+		It("starts the missing instances", func() {
+			startAuctions := bbs.GetLRPStartAuctions()
+			Ω(startAuctions).Should(HaveLen(1))
+			Ω(startAuctions[0].Index).Should(Equal(1))
+		})
+
+		Context("when fetching the actuals fails", func() {
+			BeforeEach(func() {
+				bbs.ActualLRPsErr = errors.New("oh no")
+			})
+
+			It("does not start anything", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("logs and reports the error", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.fetch-actuals-failed"))
+
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.LastError).Should(Equal("oh no"))
+			})
+		})
+
+		Context("when no instances are desired", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = 0
+			})
+
+			It("stops the actuals", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+					{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						Index:        0,
+						InstanceGuid: "a",
+					},
+				}))
+			})
+
+			It("releases the process instead of holding it", func() {
+				Ω(stackHolder.HoldCallCount()).Should(BeZero())
+				Ω(stackHolder.ReleaseCallCount()).Should(Equal(1))
+				Ω(stackHolder.ReleaseArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+			})
+
+			Context("when the domain of the process is not fresh", func() {
+				BeforeEach(func() {
+					freshnessBBS.IsFreshReturns(false, nil)
+				})
+
+				It("still stops the actuals, as the process is still desired", func() {
+					Ω(bbs.GetStopLRPInstances()).Should(HaveLen(1))
+					Ω(freshnessBBS.IsFreshCallCount()).Should(BeZero())
+				})
+			})
+		})
+
+		Context("when no executor runs the stack of the process", func() {
+			BeforeEach(func() {
+				stackHolder.HoldReturns(true)
+			})
+
+			It("holds the process", func() {
+				Ω(stackHolder.HoldCallCount()).Should(Equal(1))
+
+				processGuid, stack := stackHolder.HoldArgsForCall(0)
+				Ω(processGuid).Should(Equal("the-app-guid-the-app-version"))
+				Ω(stack).Should(Equal("some-stack"))
+			})
+
+			It("does not start anything", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("reports, logs and counts that it is pending", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.PendingUnschedulable).Should(BeTrue())
+
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.pending-unschedulable"))
+				Ω(emitter.Counter(metrics.UnschedulableHolds)).Should(Equal(1))
+			})
+		})
+
+		Context("when the desired LRP is invalid", func() {
+			BeforeEach(func() {
+				desiredLRP.MemoryMB = 0
+				desiredLRP.Actions = []models.ExecutorAction{
+					models.Parallel(models.ExecutorAction{Action: models.RunAction{}}),
+				}
+			})
+
+			It("does not start anything", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+
+			It("leaves the instances it already has alone", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+				Ω(bbs.GetLRPStopAuctions()).Should(BeEmpty())
+			})
+
+			It("reports what is wrong with it", func() {
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.Violations).Should(Equal([]validation.Violation{
+					{Field: "memory_mb", Message: "must be positive"},
+					{Field: "actions[0].parallel.actions[0].run.path", Message: "is required"},
+				}))
+				Ω(processStatus.LastError).Should(Equal(ErrInvalidDesiredLRP.Error()))
+			})
+
+			It("logs and counts it", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say(`processor.reconcile.invalid-desired-lrp.*"field":"memory_mb"`))
+				Ω(emitter.Counter(metrics.InvalidDesiredLRPs)).Should(Equal(1))
+			})
+		})
+
+		Context("when a negative number of instances is desired", func() {
+			BeforeEach(func() {
+				desiredLRP.Instances = -1
+			})
+
+			It("refuses it instead of stopping every instance", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+				Ω(bbs.GetLRPStopAuctions()).Should(BeEmpty())
+
+				processStatus, ok := registry.Process("the-app-guid-the-app-version")
+				Ω(ok).Should(BeTrue())
+				Ω(processStatus.Violations).Should(Equal([]validation.Violation{
+					{Field: "instances", Message: "must not be negative"},
+				}))
+			})
+		})
+
+		Context("when the process is removed without its domain", func() {
+			removeProcess := func() {
+				processor.ProcessDesiredChange(models.DesiredLRPChange{
+					Before: &models.DesiredLRP{ProcessGuid: "the-app-guid-the-app-version"},
+				})
+			}
+
+			It("checks the freshness of the domain it was last desired in", func() {
+				removeProcess()
+
+				Ω(freshnessBBS.IsFreshCallCount()).Should(Equal(1))
+				Ω(freshnessBBS.IsFreshArgsForCall(0)).Should(Equal("some-domain"))
+			})
+		})
+	})
+
+	Describe("working on the same process from two places at once", func() {
+		var blocked, release chan struct{}
+
+		BeforeEach(func() {
+			desiredLRP.Instances = 1
+
+			// the first start blocks once its auction has been requested
+			blocked = make(chan struct{})
+			release = make(chan struct{})
+			restartPolicy.RecordStartStub = func(processGuid string, index int) {
+				select {
+				case <-blocked:
+				default:
+					close(blocked)
+					<-release
+				}
+			}
+		})
+
+		It("fetches the actuals for the second once the first is done", func() {
+			firstDone := make(chan struct{})
+			go func() {
+				defer close(firstDone)
+				processor.Reconcile(desiredLRP, desiredLRP.Instances)
+			}()
+
+			Eventually(blocked).Should(BeClosed())
+
+			secondDone := make(chan struct{})
+			go func() {
+				defer close(secondDone)
+				processor.ProcessDesiredChange(models.DesiredLRPChange{
+					Before: &desiredLRP,
+					After:  &desiredLRP,
+				})
+			}()
+
+			Consistently(secondDone).ShouldNot(BeClosed())
+
+			startAuctions := bbs.GetLRPStartAuctions()
+			Ω(startAuctions).Should(HaveLen(1))
+			bbs.ActualLRPs = []models.ActualLRP{
+				{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: startAuctions[0].InstanceGuid, Index: 0},
+			}
+
+			close(release)
+
+			Eventually(firstDone).Should(BeClosed())
+			Eventually(secondDone).Should(BeClosed())
+			Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+		})
+	})
+
+	Describe("starting the same index more than once", func() {
+		var actualLRPs []models.ActualLRP
+
+		reconcile := func() string {
+			reconcileAgainst(desiredLRP, 1, actualLRPs)
+
+			startAuctions := bbs.GetLRPStartAuctions()
+			Ω(startAuctions).ShouldNot(BeEmpty())
+
+			return startAuctions[len(startAuctions)-1].InstanceGuid
+		}
+
+		BeforeEach(func() {
+			actualLRPs = nil
+		})
+
+		Context("with random instance guids", func() {
+			It("starts a new instance every time", func() {
+				Ω(reconcile()).ShouldNot(Equal(reconcile()))
+			})
+		})
+
+		Context("with deterministic instance guids", func() {
+			var fakeDesiredIndexBBS *desiredwatch_fakes.FakeDesiredIndexBBS
+
+			BeforeEach(func() {
+				instanceGuids = instanceguid.NewDeterministic()
+
+				fakeDesiredIndexBBS = new(desiredwatch_fakes.FakeDesiredIndexBBS)
+				fakeDesiredIndexBBS.GetDesiredLRPIndexReturns(7, nil)
+				desiredIndexBBS = fakeDesiredIndexBBS
+			})
+
+			It("requests the same instance until it shows up", func() {
+				Ω(reconcile()).Should(Equal(reconcile()))
+			})
+
+			It("derives the instance guid from the process guid, index, desired index and generation", func() {
+				expectedGuid, err := instanceguid.NewDeterministic().InstanceGuid("the-app-guid-the-app-version", 0, 7, 0)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(reconcile()).Should(Equal(expectedGuid))
+				Ω(fakeDesiredIndexBBS.GetDesiredLRPIndexArgsForCall(0)).Should(Equal("the-app-guid-the-app-version"))
+			})
+
+			It("starts instances of a new version of the desired LRP under new guids", func() {
+				firstGuid := reconcile()
+
+				fakeDesiredIndexBBS.GetDesiredLRPIndexReturns(8, nil)
+				Ω(reconcile()).ShouldNot(Equal(firstGuid))
+			})
+
+			It("does not hand out the guids of an earlier version once restarted", func() {
+				firstGuid := reconcile()
+
+				fakeDesiredIndexBBS.GetDesiredLRPIndexReturns(8, nil)
+				processor = newProcessor()
+
+				Ω(reconcile()).ShouldNot(Equal(firstGuid))
+			})
+
+			Context("when the desired index cannot be fetched", func() {
+				BeforeEach(func() {
+					fakeDesiredIndexBBS.GetDesiredLRPIndexReturns(0, errors.New("oh no"))
+				})
+
+				It("starts nothing and reports the error", func() {
+					reconcileAgainst(desiredLRP, 1, nil)
+
+					Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+
+					processStatus, ok := registry.Process("the-app-guid-the-app-version")
+					Ω(ok).Should(BeTrue())
+					Ω(processStatus.LastError).Should(Equal("oh no"))
+
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.fetch-desired-index-failed"))
+				})
+			})
+
+			It("starts a new generation once the instance has shown up and gone away", func() {
+				firstGuid := reconcile()
+
+				actualLRPs = []models.ActualLRP{
+					{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: firstGuid, Index: 0},
+				}
+				reconcileAgainst(desiredLRP, 1, actualLRPs)
+
+				actualLRPs = nil
+				Ω(reconcile()).ShouldNot(Equal(firstGuid))
+			})
+
+			It("skips generations whose instance is already running", func() {
+				takenGuid, err := instanceguid.NewDeterministic().InstanceGuid("the-app-guid-the-app-version", 0, 7, 0)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				actualLRPs = []models.ActualLRP{
+					{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: takenGuid, Index: 1},
+				}
+
+				Ω(reconcile()).ShouldNot(Equal(takenGuid))
+			})
+
+			Context("when the auction for the index already exists", func() {
+				BeforeEach(func() {
+					bbs.LRPStartAuctionErr = storeadapter.ErrorKeyExists
+				})
+
+				It("takes it to be the same start, not a failure", func() {
+					reconcile()
+
+					processStatus, ok := registry.Process("the-app-guid-the-app-version")
+					Ω(ok).Should(BeTrue())
+					Ω(processStatus.Failures).Should(BeEmpty())
+					Ω(processStatus.LastError).Should(BeEmpty())
+
+					Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.start-already-requested"))
+					Ω(emitter.Counter(metrics.BBSWriteFailures)).Should(BeZero())
+				})
+			})
+		})
+	})
+
+	Describe("taking over a process that may be mid-update", func() {
+		var actualLRPs []models.ActualLRP
+
+		BeforeEach(func() {
+			updateStrategy = UpdateStrategy{
+				Rolling:  true,
+				MaxSurge: 1,
+			}
+
+			actualLRPs = []models.ActualLRP{
+				{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: "a", Index: 0, State: models.ActualLRPStateRunning},
+				{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: "b", Index: 0, State: models.ActualLRPStateRunning},
+				{ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: "c", Index: 1, State: models.ActualLRPStateRunning},
+			}
+		})
+
+		JustBeforeEach(func() {
+			reconcileAgainst(desiredLRP, desiredLRP.Instances, actualLRPs)
+		})
+
+		Context("when some of its indices have more than one instance", func() {
+			It("logs that it resumes the update", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say(`processor.reconcile.resuming-interrupted-update.*"duplicated-indices":\[0\]`))
+			})
+
+			It("replaces its instances instead of stopping duplicates", func() {
+				Ω(bbs.GetLRPStopAuctions()).Should(BeEmpty())
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(1))
+				Ω(startAuctions[0].Index).Should(Equal(0))
+			})
+
+			It("does not resume it again once it has been seen", func() {
+				reconcileAgainst(desiredLRP, desiredLRP.Instances, actualLRPs)
+
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("resuming-interrupted-update"))
+				Ω(logger.TestSink.Buffer).ShouldNot(gbytes.Say("resuming-interrupted-update"))
+			})
+		})
+
+		Context("when every index has one instance", func() {
+			BeforeEach(func() {
+				actualLRPs = actualLRPs[1:]
+			})
+
+			It("does not update anything", func() {
+				Ω(logger.TestSink.Buffer).ShouldNot(gbytes.Say("update-started"))
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+			})
+		})
+
+		Context("when it has been seen before", func() {
+			JustBeforeEach(func() {
+				reconcileAgainst(desiredLRP, desiredLRP.Instances, append(actualLRPs, models.ActualLRP{
+					ProcessGuid: "the-app-guid-the-app-version", InstanceGuid: "d", Index: 1, State: models.ActualLRPStateRunning,
+				}))
+			})
+
+			BeforeEach(func() {
+				actualLRPs = actualLRPs[1:]
+			})
+
+			It("resolves duplicates as usual", func() {
+				Ω(logger.TestSink.Buffer).ShouldNot(gbytes.Say("resuming-interrupted-update"))
+				Ω(bbs.GetLRPStopAuctions()).ShouldNot(BeEmpty())
+			})
+		})
+	})
+
+	Describe("changing the spec of a desired LRP", func() {
+		var oldLRP models.DesiredLRP
+		var newLRP models.DesiredLRP
+
+		oldActual := func(guid string, index int) models.ActualLRP {
+			return models.ActualLRP{
+				ProcessGuid:  "the-app-guid-the-app-version",
+				InstanceGuid: guid,
+				Index:        index,
+				State:        models.ActualLRPStateRunning,
+			}
+		}
+
+		replacementFor := func(index int, state models.ActualLRPState) models.ActualLRP {
+			for _, startAuction := range bbs.GetLRPStartAuctions() {
+				if startAuction.Index == index {
+					return models.ActualLRP{
+						ProcessGuid:  "the-app-guid-the-app-version",
+						InstanceGuid: startAuction.InstanceGuid,
+						Index:        index,
+						State:        state,
+					}
+				}
+			}
+
+			Fail("no replacement was started")
+			return models.ActualLRP{}
+		}
+
+		stopOf := func(actual models.ActualLRP) models.StopLRPInstance {
+			return models.StopLRPInstance{
+				ProcessGuid:  actual.ProcessGuid,
+				InstanceGuid: actual.InstanceGuid,
+				Index:        actual.Index,
+			}
+		}
+
+		BeforeEach(func() {
+			oldLRP = desiredLRP
+
+			newLRP = desiredLRP
+			newLRP.Actions = []models.ExecutorAction{
+				{
+					Action: models.RunAction{
+						Path: "some-new-run-action-path",
+					},
+				},
+			}
+
+			bbs.ActualLRPs = []models.ActualLRP{oldActual("a", 0), oldActual("b", 1)}
+		})
+
+		JustBeforeEach(func() {
+			processor.ProcessDesiredChange(models.DesiredLRPChange{
+				Before: &oldLRP,
+				After:  &newLRP,
+			})
+		})
+
+		Context("without rolling updates", func() {
+			It("leaves the existing instances alone", func() {
+				Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+			})
+		})
+
+		Context("with rolling updates", func() {
+			BeforeEach(func() {
+				updateStrategy = UpdateStrategy{
+					Rolling:        true,
+					MaxSurge:       1,
+					MaxUnavailable: 0,
+				}
+			})
+
+			reconcileWith := func(actuals ...models.ActualLRP) {
+				bbs.ActualLRPs = actuals
+				processor.Reconcile(newLRP, newLRP.Instances)
+			}
+
+			It("starts one replacement with the new spec", func() {
+				startAuctions := bbs.GetLRPStartAuctions()
+				Ω(startAuctions).Should(HaveLen(1))
+				Ω(startAuctions[0].Index).Should(Equal(0))
+				Ω(startAuctions[0].DesiredLRP.Actions).Should(Equal(newLRP.Actions))
+				Ω(startAuctions[0].InstanceGuid).ShouldNot(Equal("a"))
+			})
+
+			It("keeps the old instances running", func() {
+				Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+			})
+
+			It("logs that the update started", func() {
+				Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.desired-lrp-change.update-started"))
+			})
+
+			Context("while the replacement is starting", func() {
+				JustBeforeEach(func() {
+					bbs.ActualLRPs = append(bbs.ActualLRPs, replacementFor(0, models.ActualLRPStateStarting))
+					processor.Reconcile(newLRP, newLRP.Instances)
+				})
+
+				It("does not start another replacement", func() {
+					Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+				})
+
+				It("does not stop anything, even though the index is duplicated", func() {
+					Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+					Ω(bbs.GetLRPStopAuctions()).Should(BeEmpty())
+				})
+			})
+
+			Context("once the replacement is running", func() {
+				var replacement models.ActualLRP
+
+				JustBeforeEach(func() {
+					replacement = replacementFor(0, models.ActualLRPStateRunning)
+					bbs.ActualLRPs = append(bbs.ActualLRPs, replacement)
+
+					processor.ProcessDesiredChange(models.DesiredLRPChange{
+						Before: &newLRP,
+						After:  &newLRP,
+					})
+				})
+
+				It("stops the instance it replaces", func() {
+					Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+						stopOf(oldActual("a", 0)),
+					}))
+				})
+
+				It("starts the next replacement", func() {
+					startAuctions := bbs.GetLRPStartAuctions()
+					Ω(startAuctions).Should(HaveLen(2))
+					Ω(startAuctions[1].Index).Should(Equal(1))
+				})
+
+				Context("and every instance has been replaced", func() {
+					JustBeforeEach(func() {
+						bbs.ActualLRPs = []models.ActualLRP{
+							replacement,
+							oldActual("b", 1),
+							replacementFor(1, models.ActualLRPStateRunning),
+						}
+
+						processor.Reconcile(newLRP, newLRP.Instances)
+					})
+
+					It("stops the last old instance", func() {
+						Ω(bbs.GetStopLRPInstances()).Should(ContainElement(stopOf(oldActual("b", 1))))
+					})
+
+					It("waits for the old instances to go away before completing", func() {
+						Ω(logger.TestSink.Buffer).ShouldNot(gbytes.Say("update-complete"))
+					})
+
+					Context("and the old instances are gone", func() {
+						JustBeforeEach(func() {
+							bbs.ActualLRPs = []models.ActualLRP{
+								replacement,
+								replacementFor(1, models.ActualLRPStateRunning),
+							}
+
+							processor.Reconcile(newLRP, newLRP.Instances)
+						})
+
+						It("completes the update", func() {
+							Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.update-complete"))
+						})
+
+						It("records the rollout", func() {
+							processStatus, _ := registry.Process("the-app-guid-the-app-version")
+							Ω(processStatus.Rollouts).Should(Equal([]status.Rollout{
+								{
+									Before:    oldLRP,
+									After:     newLRP,
+									StartedAt: time.Unix(1000, 0),
+									EndedAt:   time.Unix(1000, 0),
+									Outcome:   status.RolloutCompleted,
+								},
+							}))
+						})
+
+						It("goes back to reconciling normally", func() {
+							scaledLRP := newLRP
+							scaledLRP.Instances = 3
+
+							processor.Reconcile(scaledLRP, scaledLRP.Instances)
+
+							startAuctions := bbs.GetLRPStartAuctions()
+							Ω(startAuctions).Should(HaveLen(3))
+							Ω(startAuctions[2].Index).Should(Equal(2))
+						})
+					})
+				})
+			})
+
+			Context("when instances may be unavailable", func() {
+				BeforeEach(func() {
+					updateStrategy.MaxSurge = 0
+					updateStrategy.MaxUnavailable = 1
+				})
+
+				It("stops an old instance without waiting for its replacement", func() {
+					Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+					Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+						stopOf(oldActual("a", 0)),
+					}))
+				})
+			})
+
+			Context("when more instances are desired by the new spec", func() {
+				BeforeEach(func() {
+					newLRP.Instances = 3
+				})
+
+				It("starts the new instances right away", func() {
+					startAuctions := bbs.GetLRPStartAuctions()
+					Ω(startAuctions).Should(HaveLen(2))
+					Ω(startAuctions[0].Index).Should(Equal(0))
+					Ω(startAuctions[1].Index).Should(Equal(2))
+				})
+			})
+
+			Context("when fewer instances are desired by the new spec", func() {
+				BeforeEach(func() {
+					newLRP.Instances = 1
+				})
+
+				It("stops the old instances that are no longer desired", func() {
+					Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+						stopOf(oldActual("b", 1)),
+					}))
+				})
+			})
+
+			Context("when the new spec is invalid", func() {
+				BeforeEach(func() {
+					newLRP.Actions = []models.ExecutorAction{
+						{Action: models.RunAction{}},
+					}
+				})
+
+				It("does not replace anything", func() {
+					Ω(bbs.GetLRPStartAuctions()).Should(BeEmpty())
+					Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+				})
+			})
+
+			Context("when only the number of instances changes", func() {
+				BeforeEach(func() {
+					newLRP = oldLRP
+					newLRP.Instances = 3
+				})
+
+				It("does not replace anything", func() {
+					startAuctions := bbs.GetLRPStartAuctions()
+					Ω(startAuctions).Should(HaveLen(1))
+					Ω(startAuctions[0].Index).Should(Equal(2))
+				})
+			})
+
+			Context("with a canary", func() {
+				BeforeEach(func() {
+					updateStrategy.MaxSurge = 2
+					updateStrategy.Canary = true
+					updateStrategy.CanarySoakPeriod = time.Minute
+				})
+
+				It("only replaces the canary index at first", func() {
+					startAuctions := bbs.GetLRPStartAuctions()
+					Ω(startAuctions).Should(HaveLen(1))
+					Ω(startAuctions[0].Index).Should(Equal(0))
+				})
+
+				Context("while the canary is soaking", func() {
+					JustBeforeEach(func() {
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+						timeProvider.Increment(30 * time.Second)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+					})
+
+					It("keeps every old instance, including the one at the canary index", func() {
+						Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+					})
+
+					It("does not replace anything else", func() {
+						Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+					})
+
+					Context("when an old instance at another index goes away", func() {
+						JustBeforeEach(func() {
+							reconcileWith(oldActual("a", 0), replacementFor(0, models.ActualLRPStateRunning))
+						})
+
+						It("restarts the index with the old spec", func() {
+							startAuctions := bbs.GetLRPStartAuctions()
+							Ω(startAuctions).Should(HaveLen(2))
+							Ω(startAuctions[1].Index).Should(Equal(1))
+							Ω(startAuctions[1].DesiredLRP.Actions).Should(Equal(oldLRP.Actions))
+						})
+
+						It("does not restart it again while the restart is on its way", func() {
+							reconcileWith(oldActual("a", 0), replacementFor(0, models.ActualLRPStateRunning))
+
+							Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(2))
+						})
+
+						It("replaces the restarted instance once the canary has passed", func() {
+							restarted := oldActual(bbs.GetLRPStartAuctions()[1].InstanceGuid, 1)
+
+							timeProvider.Increment(30 * time.Second)
+							reconcileWith(oldActual("a", 0), restarted, replacementFor(0, models.ActualLRPStateRunning))
+
+							startAuctions := bbs.GetLRPStartAuctions()
+							Ω(startAuctions).Should(HaveLen(3))
+							Ω(startAuctions[2].Index).Should(Equal(1))
+							Ω(startAuctions[2].DesiredLRP.Actions).Should(Equal(newLRP.Actions))
+						})
+					})
+
+					Context("once it has been running for the soak period", func() {
+						JustBeforeEach(func() {
+							timeProvider.Increment(30 * time.Second)
+							reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+						})
+
+						It("goes on with the rest of the update", func() {
+							Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.canary-passed"))
+
+							Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+								stopOf(oldActual("a", 0)),
+							}))
+
+							startAuctions := bbs.GetLRPStartAuctions()
+							Ω(startAuctions).Should(HaveLen(2))
+							Ω(startAuctions[1].Index).Should(Equal(1))
+						})
+					})
+
+					Context("when the canary crashes", func() {
+						JustBeforeEach(func() {
+							reconcileWith(oldActual("a", 0), oldActual("b", 1))
+						})
+
+						It("halts the update and keeps the old instances", func() {
+							reconcileWith(oldActual("a", 0), oldActual("b", 1))
+
+							Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+							Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+						})
+
+						It("logs and counts that the canary failed", func() {
+							Ω(logger.TestSink.Buffer).Should(gbytes.Say(`processor.reconcile.canary-failed.*"process-guid":"the-app-guid-the-app-version"`))
+							Ω(emitter.Counter(metrics.CanariesFailed)).Should(Equal(1))
+						})
+
+						It("reports that the canary failed", func() {
+							processStatus, ok := registry.Process("the-app-guid-the-app-version")
+							Ω(ok).Should(BeTrue())
+							Ω(processStatus.CanaryFailed).Should(BeTrue())
+						})
+
+						It("restarts old instances that go away with the old spec", func() {
+							reconcileWith(oldActual("b", 1))
+
+							startAuctions := bbs.GetLRPStartAuctions()
+							Ω(startAuctions).Should(HaveLen(2))
+							Ω(startAuctions[1].Index).Should(Equal(0))
+							Ω(startAuctions[1].DesiredLRP.Actions).Should(Equal(oldLRP.Actions))
+						})
+					})
+				})
+
+				Context("when the canary is not running yet", func() {
+					JustBeforeEach(func() {
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateStarting))
+						timeProvider.Increment(time.Hour)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateStarting))
+					})
+
+					It("does not count the time towards the soak period", func() {
+						Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+						Ω(bbs.GetStopLRPInstances()).Should(BeEmpty())
+					})
+				})
+			})
+
+			Context("with a rollback deadline", func() {
+				BeforeEach(func() {
+					updateStrategy.RollbackDeadline = 5 * time.Minute
+				})
+
+				Context("when a replacement is running before the deadline", func() {
+					JustBeforeEach(func() {
+						timeProvider.Increment(4 * time.Minute)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+						timeProvider.Increment(time.Minute)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+					})
+
+					It("goes on with the update", func() {
+						Ω(rollbackBBS.ChangeDesiredLRPCallCount()).Should(BeZero())
+						Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(2))
+					})
+				})
+
+				Context("when a replacement is not running by the deadline", func() {
+					var replacement models.ActualLRP
+
+					JustBeforeEach(func() {
+						replacement = replacementFor(0, models.ActualLRPStateStarting)
+
+						timeProvider.Increment(5 * time.Minute)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacement)
+					})
+
+					It("writes the previous desired LRP back", func() {
+						Ω(rollbackBBS.ChangeDesiredLRPCallCount()).Should(Equal(1))
+
+						change := rollbackBBS.ChangeDesiredLRPArgsForCall(0)
+						Ω(*change.Before).Should(Equal(newLRP))
+						Ω(*change.After).Should(Equal(oldLRP))
+					})
+
+					It("logs and counts the rollback", func() {
+						Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.rolling-back"))
+						Ω(emitter.Counter(metrics.UpdatesRolledBack)).Should(Equal(1))
+					})
+
+					It("records what was rolled back and why", func() {
+						processStatus, _ := registry.Process("the-app-guid-the-app-version")
+						Ω(processStatus.Rollouts).Should(Equal([]status.Rollout{
+							{
+								Before:    oldLRP,
+								After:     newLRP,
+								StartedAt: time.Unix(1000, 0),
+								EndedAt:   time.Unix(1000, 0).Add(5 * time.Minute),
+								Outcome:   status.RolloutRolledBack,
+								Reason:    "instances at indices [0] were not running 5m0s after they were requested",
+							},
+						}))
+					})
+
+					Context("once the rollback is reconciled", func() {
+						JustBeforeEach(func() {
+							bbs.ActualLRPs = []models.ActualLRP{oldActual("a", 0), oldActual("b", 1), replacement}
+							processor.Reconcile(oldLRP, oldLRP.Instances)
+						})
+
+						It("keeps the old instances and stops the replacement", func() {
+							Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+							Ω(bbs.GetStopLRPInstances()).Should(Equal([]models.StopLRPInstance{
+								stopOf(replacement),
+							}))
+						})
+
+						It("does not roll the rollback back", func() {
+							timeProvider.Increment(time.Hour)
+							processor.Reconcile(oldLRP, oldLRP.Instances)
+
+							Ω(rollbackBBS.ChangeDesiredLRPCallCount()).Should(Equal(1))
+						})
+
+						It("does not record the rollback as a rollout of its own", func() {
+							bbs.ActualLRPs = []models.ActualLRP{oldActual("a", 0), oldActual("b", 1)}
+							processor.Reconcile(oldLRP, oldLRP.Instances)
+
+							Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.update-complete"))
+
+							processStatus, _ := registry.Process("the-app-guid-the-app-version")
+							Ω(processStatus.Rollouts).Should(HaveLen(1))
+						})
+					})
+				})
+
+				Context("when writing the previous desired LRP back fails", func() {
+					BeforeEach(func() {
+						rollbackBBS.ChangeDesiredLRPReturns(errors.New("oh no"))
+					})
+
+					JustBeforeEach(func() {
+						timeProvider.Increment(5 * time.Minute)
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateStarting))
+					})
+
+					It("logs the error and tries again next time", func() {
+						Ω(logger.TestSink.Buffer).Should(gbytes.Say("processor.reconcile.rollback-failed"))
+
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateStarting))
+						Ω(rollbackBBS.ChangeDesiredLRPCallCount()).Should(Equal(2))
+					})
+
+					It("does not record a rollout", func() {
+						processStatus, _ := registry.Process("the-app-guid-the-app-version")
+						Ω(processStatus.Rollouts).Should(BeEmpty())
+						Ω(processStatus.LastError).Should(Equal("oh no"))
+					})
+				})
+
+				Context("when the canary fails", func() {
+					BeforeEach(func() {
+						updateStrategy.Canary = true
+						updateStrategy.CanarySoakPeriod = time.Minute
+					})
+
+					JustBeforeEach(func() {
+						reconcileWith(oldActual("a", 0), oldActual("b", 1), replacementFor(0, models.ActualLRPStateRunning))
+						reconcileWith(oldActual("a", 0), oldActual("b", 1))
+					})
+
+					It("rolls back right away", func() {
+						Ω(rollbackBBS.ChangeDesiredLRPCallCount()).Should(Equal(1))
+
+						processStatus, _ := registry.Process("the-app-guid-the-app-version")
+						Ω(processStatus.Rollouts).Should(HaveLen(1))
+						Ω(processStatus.Rollouts[0].Reason).Should(Equal("canary failed"))
+					})
+				})
+			})
+
+			Context("when the desired LRP is deleted mid-update", func() {
+				JustBeforeEach(func() {
+					processor.ProcessDesiredChange(models.DesiredLRPChange{
+						Before: &newLRP,
+						After:  nil,
+					})
+				})
+
+				It("stops every instance", func() {
+					stopInstances := bbs.GetStopLRPInstances()
+					Ω(stopInstances).Should(HaveLen(2))
+					Ω(stopInstances).Should(ContainElement(stopOf(oldActual("a", 0))))
+					Ω(stopInstances).Should(ContainElement(stopOf(oldActual("b", 1))))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+type RollbackBBS interface {
+	ChangeDesiredLRP(change models.DesiredLRPChange) error
+}
+
+// rollbackReason reports why the update has to be rolled back, or nothing if
+// it does not. Rollbacks are never rolled back themselves.
+func (p *processor) rollbackReason(update *rollingUpdate) string {
+	if p.rollbackBBS == nil || p.updateStrategy.RollbackDeadline <= 0 {
+		return ""
+	}
+
+	update.Lock()
+	defer update.Unlock()
+
+	// A resumed update does not know the spec it replaces.
+	if update.rollback || !specChanged(update.before, update.desiredLRP) {
+		return ""
+	}
+
+	if update.canary.failed {
+		return "canary failed"
+	}
+
+	now := p.timeProvider.Time()
+
+	overdue := []int{}
+	for index, requestedAt := range update.requestedAt {
+		if now.Sub(requestedAt) >= p.updateStrategy.RollbackDeadline {
+			overdue = append(overdue, index)
+		}
+	}
+
+	if len(overdue) == 0 {
+		return ""
+	}
+
+	sort.Ints(overdue)
+
+	return fmt.Sprintf("instances at indices %v were not running %s after they were requested", overdue, p.updateStrategy.RollbackDeadline)
+}
+
+// rollBack writes the spec the update replaced back to the BBS, and replaces
+// the update with one back to that spec. The instances the update started
+// are the ones the rollback replaces; those it had yet to replace are kept.
+func (p *processor) rollBack(logger lager.Logger, report *status.ProcessStatus, update *rollingUpdate, actualLRPs []models.ActualLRP, reason string) error {
+	update.Lock()
+
+	desiredLRP := update.desiredLRP
+	before := update.before
+
+	rollback := newRollingUpdate(desiredLRP, before, nil, p.timeProvider.Time())
+	rollback.rollback = true
+	rollback.canary.passed = true
+
+	for _, actual := range actualLRPs {
+		if !update.oldInstances[actual.InstanceGuid] {
+			rollback.oldInstances[actual.InstanceGuid] = true
+		}
+	}
+
+	for _, instanceGuid := range update.pendingStarts {
+		rollback.oldInstances[instanceGuid] = true
+	}
+
+	// Instances the update restarted with the spec it replaced are already
+	// of the spec the rollback goes back to.
+	for index, instanceGuid := range update.restarts {
+		rollback.pendingStarts[index] = instanceGuid
+	}
+
+	update.Unlock()
+
+	logger.Info("rolling-back", lager.Data{
+		"desired-app-message": desiredLRP,
+		"rollback-to":         before,
+		"reason":              reason,
+	})
+
+	err := p.rollbackBBS.ChangeDesiredLRP(models.DesiredLRPChange{
+		Before: &desiredLRP,
+		After:  &before,
+	})
+	if err != nil {
+		logger.Error("rollback-failed", err, lager.Data{"desired-app-message": desiredLRP})
+		report.LastError = err.Error()
+		p.emitter.IncrementCounter(metrics.BBSWriteFailures)
+		return err
+	}
+
+	p.updatesLock.Lock()
+	if p.updates[desiredLRP.ProcessGuid] == update {
+		p.updates[desiredLRP.ProcessGuid] = rollback
+	}
+	p.updatesLock.Unlock()
+
+	p.recordRollout(update, status.RolloutRolledBack, reason)
+	p.emitter.IncrementCounter(metrics.UpdatesRolledBack)
+
+	return nil
+}
+
+// recordRollout adds an update that has ended to the history of its process.
+// Rollbacks are already recorded as the outcome of the update they undo.
+func (p *processor) recordRollout(update *rollingUpdate, outcome string, reason string) {
+	update.Lock()
+	defer update.Unlock()
+
+	if update.rollback {
+		return
+	}
+
+	p.registry.RecordRollout(update.desiredLRP.ProcessGuid, status.Rollout{
+		Before:    update.before,
+		After:     update.desiredLRP,
+		StartedAt: update.startedAt,
+		EndedAt:   p.timeProvider.Time(),
+		Outcome:   outcome,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,375 @@
+package processor
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+type UpdateStrategy struct {
+	Rolling bool
+
+	// MaxSurge is how many replacements may be starting alongside the
+	// instances they replace; MaxUnavailable is how many instances may be
+	// stopped before their replacement is running.
+	MaxSurge       int
+	MaxUnavailable int
+
+	// With Canary set, only the canary index is replaced at first, and the
+	// old instances are all kept until its replacement has been running for
+	// CanarySoakPeriod.
+	Canary           bool
+	CanarySoakPeriod time.Duration
+
+	// With RollbackDeadline set, an update whose replacements are not running
+	// that long after they were requested, or whose canary fails, is rolled
+	// back to the spec it replaced.
+	RollbackDeadline time.Duration
+}
+
+// A rollingUpdate carries a process from the instances that were running
+// when its spec changed to instances of desiredLRP. It does not watch
+// anything itself: it is stepped whenever the process is reconciled.
+//
+// Updates are only kept in memory, so an app manager that takes a process
+// over mid-update, after a restart or a lock handover, cannot tell its old
+// instances from its new ones. See mayBeInterrupted for what is done about it.
+type rollingUpdate struct {
+	sync.Mutex
+
+	before     models.DesiredLRP
+	desiredLRP models.DesiredLRP
+	startedAt  time.Time
+	rollback   bool
+
+	oldInstances  map[string]bool
+	stoppedOld    map[string]bool
+	pendingStarts map[int]string
+	restarts      map[int]string
+	requestedAt   map[int]time.Time
+	unavailable   map[int]bool
+	canary        canary
+}
+
+func newRollingUpdate(before, desiredLRP models.DesiredLRP, actualLRPs []models.ActualLRP, startedAt time.Time) *rollingUpdate {
+	oldInstances := map[string]bool{}
+	for _, actual := range actualLRPs {
+		oldInstances[actual.InstanceGuid] = true
+	}
+
+	return &rollingUpdate{
+		before:     before,
+		desiredLRP: desiredLRP,
+		startedAt:  startedAt,
+
+		oldInstances:  oldInstances,
+		stoppedOld:    map[string]bool{},
+		pendingStarts: map[int]string{},
+		restarts:      map[int]string{},
+		requestedAt:   map[int]time.Time{},
+		unavailable:   map[int]bool{},
+	}
+}
+
+// specChanged reports whether the instances of before need to be replaced
+// to run after. Instance counts and routes can change in place.
+func specChanged(before, after models.DesiredLRP) bool {
+	before.Instances, after.Instances = 0, 0
+	before.Routes, after.Routes = nil, nil
+
+	return !reflect.DeepEqual(before, after)
+}
+
+// retarget points the update at a new instance count, as long as the spec
+// it is rolling out has not changed.
+func (update *rollingUpdate) retarget(desiredLRP models.DesiredLRP) bool {
+	update.Lock()
+	defer update.Unlock()
+
+	if specChanged(update.desiredLRP, desiredLRP) {
+		return false
+	}
+
+	update.desiredLRP = desiredLRP
+	return true
+}
+
+func (update *rollingUpdate) target() models.DesiredLRP {
+	update.Lock()
+	defer update.Unlock()
+
+	return update.desiredLRP
+}
+
+// firstSight reports whether this app manager has not driven the process
+// before, and remembers that it now has.
+func (p *processor) firstSight(processGuid string) bool {
+	p.updatesLock.Lock()
+	defer p.updatesLock.Unlock()
+
+	seen := p.seen[processGuid]
+	p.seen[processGuid] = true
+
+	return !seen
+}
+
+// mayBeInterrupted reports whether an update of a process seen for the first
+// time may have been interrupted, because some of its indices have more than
+// one instance. Resolving those as ordinary duplicates might keep an instance
+// of the old spec and stop the one of the new, so the update is resumed
+// instead: every instance is replaced with the current spec.
+//
+// An interrupted update that had already left one instance per index can
+// not be told apart from a process that is not updating, and is not resumed.
+func mayBeInterrupted(logger lager.Logger, desiredLRP models.DesiredLRP, actualLRPs []models.ActualLRP) bool {
+	duplicated := duplicatedIndices(actualLRPs)
+	if len(duplicated) == 0 {
+		return false
+	}
+
+	logger.Info("resuming-interrupted-update", lager.Data{
+		"desired-app-message": desiredLRP,
+		"duplicated-indices":  duplicated,
+	})
+
+	return true
+}
+
+func (p *processor) forgetSeen(processGuid string) {
+	p.updatesLock.Lock()
+	defer p.updatesLock.Unlock()
+
+	delete(p.seen, processGuid)
+}
+
+func duplicatedIndices(actualLRPs []models.ActualLRP) []int {
+	counts := map[int]int{}
+	for _, actual := range actualLRPs {
+		counts[actual.Index]++
+	}
+
+	duplicated := []int{}
+	for index, count := range counts {
+		if count > 1 {
+			duplicated = append(duplicated, index)
+		}
+	}
+
+	sort.Ints(duplicated)
+
+	return duplicated
+}
+
+func (p *processor) activeUpdate(processGuid string) *rollingUpdate {
+	p.updatesLock.Lock()
+	defer p.updatesLock.Unlock()
+
+	return p.updates[processGuid]
+}
+
+func (p *processor) beginUpdate(logger lager.Logger, before, desiredLRP models.DesiredLRP, actualLRPs []models.ActualLRP) *rollingUpdate {
+	logger.Info("update-started", lager.Data{
+		"desired-app-message": desiredLRP,
+		"old-instances":       len(actualLRPs),
+	})
+
+	update := newRollingUpdate(before, desiredLRP, actualLRPs, p.timeProvider.Time())
+
+	p.updatesLock.Lock()
+	p.updates[desiredLRP.ProcessGuid] = update
+	p.updatesLock.Unlock()
+
+	return update
+}
+
+func (p *processor) endUpdate(processGuid string, update *rollingUpdate) {
+	p.updatesLock.Lock()
+	defer p.updatesLock.Unlock()
+
+	if p.updates[processGuid] == update {
+		delete(p.updates, processGuid)
+	}
+}
+
+// stepUpdate makes as much progress on the update as the strategy allows
+// given the current actual LRPs, and reports whether the update is done.
+func (p *processor) stepUpdate(logger lager.Logger, report *status.ProcessStatus, update *rollingUpdate, actualLRPs []models.ActualLRP, auctions auctionsInFlight) bool {
+	update.Lock()
+	defer update.Unlock()
+
+	desiredLRP := update.desiredLRP
+
+	oldByIndex := map[int][]models.ActualLRP{}
+	newByIndex := map[int]bool{}
+	runningByIndex := map[int]bool{}
+	oldRemaining := 0
+
+	for _, actual := range actualLRPs {
+		if update.oldInstances[actual.InstanceGuid] {
+			oldByIndex[actual.Index] = append(oldByIndex[actual.Index], actual)
+			oldRemaining++
+
+			if update.restarts[actual.Index] == actual.InstanceGuid {
+				delete(update.restarts, actual.Index)
+			}
+			continue
+		}
+
+		newByIndex[actual.Index] = true
+		if actual.State == models.ActualLRPStateRunning {
+			runningByIndex[actual.Index] = true
+		}
+
+		if update.pendingStarts[actual.Index] == actual.InstanceGuid {
+			delete(update.pendingStarts, actual.Index)
+		}
+	}
+
+	for index := range update.requestedAt {
+		if runningByIndex[index] || index >= desiredLRP.Instances {
+			delete(update.requestedAt, index)
+		}
+	}
+
+	// An index that has lost its instance is not left empty while the update
+	// is held back, by a canary that is soaking or has failed.
+	missing := func(index int) bool {
+		return len(oldByIndex[index]) == 0 && !newByIndex[index] &&
+			update.pendingStarts[index] == "" && update.restarts[index] == "" && !auctions.starts[index]
+	}
+
+	canaryPassed := p.checkCanary(logger, report, update, actualLRPs)
+	if update.canary.failed {
+		for index := 0; index < desiredLRP.Instances; index++ {
+			if missing(index) {
+				p.restartOld(logger, report, update, index, actualLRPs)
+			}
+		}
+
+		return false
+	}
+
+	for index, olds := range oldByIndex {
+		if !canaryPassed {
+			break
+		}
+
+		if index >= desiredLRP.Instances {
+			p.restartPolicy.RecordStop(desiredLRP.ProcessGuid, index)
+		} else if !runningByIndex[index] {
+			continue
+		}
+
+		for _, old := range olds {
+			p.stopOldInstance(logger, report, update, old)
+		}
+	}
+
+	inFlight := 0
+	for index := 0; index < desiredLRP.Instances; index++ {
+		if runningByIndex[index] {
+			delete(update.unavailable, index)
+			continue
+		}
+
+		if newByIndex[index] || update.pendingStarts[index] != "" || auctions.starts[index] {
+			inFlight++
+		}
+	}
+
+	capacity := p.updateStrategy.MaxSurge + p.updateStrategy.MaxUnavailable - inFlight
+	done := oldRemaining == 0
+
+	for index := 0; index < desiredLRP.Instances; index++ {
+		if !runningByIndex[index] {
+			done = false
+		}
+
+		if !canaryPassed && index != canaryIndex {
+			if missing(index) {
+				p.restartOld(logger, report, update, index, actualLRPs)
+			}
+			continue
+		}
+
+		if newByIndex[index] || update.pendingStarts[index] != "" || update.restarts[index] != "" || auctions.starts[index] {
+			continue
+		}
+
+		// Replacing an instance is not a restart; starting an index again
+		// after its replacement went away is.
+		replacing := len(oldByIndex[index]) > 0
+		if replacing {
+			if capacity <= 0 {
+				continue
+			}
+			capacity--
+		} else if !p.restartAllowed(logger, report, desiredLRP, index) {
+			continue
+		}
+
+		instanceGuid, err := p.startInstance(logger, report, desiredLRP, index, actualLRPs)
+		if err != nil {
+			if replacing {
+				capacity++
+			}
+			continue
+		}
+
+		update.pendingStarts[index] = instanceGuid
+		if update.requestedAt[index].IsZero() {
+			update.requestedAt[index] = p.timeProvider.Time()
+		}
+
+		if !canaryPassed {
+			update.canary.instanceGuid = instanceGuid
+			continue
+		}
+
+		if replacing && len(update.unavailable) < p.updateStrategy.MaxUnavailable {
+			update.unavailable[index] = true
+			for _, old := range oldByIndex[index] {
+				p.stopOldInstance(logger, report, update, old)
+			}
+		}
+	}
+
+	if done {
+		logger.Info("update-complete", lager.Data{"desired-app-message": desiredLRP})
+	}
+
+	return done
+}
+
+// restartOld starts an instance of the spec the update replaces at an index
+// that has lost its instance. It counts as an old instance, to be replaced
+// once the update goes ahead.
+func (p *processor) restartOld(logger lager.Logger, report *status.ProcessStatus, update *rollingUpdate, index int, actualLRPs []models.ActualLRP) {
+	if !p.restartAllowed(logger, report, update.before, index) {
+		return
+	}
+
+	instanceGuid, err := p.startInstance(logger, report, update.before, index, actualLRPs)
+	if err != nil {
+		return
+	}
+
+	update.oldInstances[instanceGuid] = true
+	update.restarts[index] = instanceGuid
+}
+
+func (p *processor) stopOldInstance(logger lager.Logger, report *status.ProcessStatus, update *rollingUpdate, actual models.ActualLRP) {
+	if update.stoppedOld[actual.InstanceGuid] {
+		return
+	}
+
+	err := p.stopInstance(logger, report, update.desiredLRP, actual)
+	if err == nil {
+		update.stoppedOld[actual.InstanceGuid] = true
+	}
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"sort"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// ScaleDownLimits bound how many instances are stopped at once, so that a
+// large scale-down drains over several reconciles. Zero values do not
+// limit anything.
+type ScaleDownLimits struct {
+	// MaxStopsPerReconcile bounds the stops for a process in one reconcile;
+	// StopLimiter bounds the stops across all processes.
+	MaxStopsPerReconcile int
+	StopLimiter          ratelimit.Limiter
+}
+
+// limitStops returns the instances that may be stopped now. They are taken
+// highest index first, so that a scale-down drains from the top; instances
+// at the same index are taken in the order the reconciler chose to stop them
+// in. The rest are left for a later reconcile to stop.
+func (p *processor) limitStops(logger lager.Logger, desiredLRP models.DesiredLRP, actualsToStop []models.ActualLRP) []models.ActualLRP {
+	sort.Stable(byIndexDescending(actualsToStop))
+
+	allowed := len(actualsToStop)
+	if max := p.scaleDownLimits.MaxStopsPerReconcile; max > 0 && allowed > max {
+		allowed = max
+	}
+
+	if limiter := p.scaleDownLimits.StopLimiter; limiter != nil {
+		for i := 0; i < allowed; i++ {
+			if !limiter.Allow() {
+				allowed = i
+				break
+			}
+		}
+	}
+
+	deferred := len(actualsToStop) - allowed
+	if deferred > 0 {
+		logger.Info("deferring-stops", lager.Data{
+			"desired-app-message": desiredLRP,
+			"stopping":            allowed,
+			"deferred":            deferred,
+		})
+
+		p.emitter.AddToCounter(metrics.StopsDeferred, uint64(deferred))
+	}
+
+	return actualsToStop[:allowed]
+}
+
+type byIndexDescending []models.ActualLRP
+
+func (b byIndexDescending) Len() int           { return len(b) }
+func (b byIndexDescending) Less(i, j int) bool { return b[i].Index > b[j].Index }
+func (b byIndexDescending) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
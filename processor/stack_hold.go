@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// holdUnschedulable reports whether a desired process has to wait for an
+// executor that runs its stack before any of its instances are auctioned.
+// Held processes are reconciled again once the stack appears.
+func (p *processor) holdUnschedulable(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP, desiredInstances int) bool {
+	if p.stackHolder == nil {
+		return false
+	}
+
+	if desiredInstances == 0 {
+		p.stackHolder.Release(desiredLRP.ProcessGuid)
+		return false
+	}
+
+	if !p.stackHolder.Hold(desiredLRP.ProcessGuid, desiredLRP.Stack) {
+		return false
+	}
+
+	logger.Info("pending-unschedulable", lager.Data{
+		"desired-app-message": desiredLRP,
+		"stack":               desiredLRP.Stack,
+	})
+	report.PendingUnschedulable = true
+	p.emitter.IncrementCounter(metrics.UnschedulableHolds)
+
+	return true
+}
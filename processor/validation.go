@@ -0,0 +1,28 @@
+package processor
+
+import (
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	"github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// refuseInvalid reports whether the desired LRP is invalid, in which case
+// none of its instances are auctioned until it is fixed. The instances it
+// already has are left alone.
+func (p *processor) refuseInvalid(logger lager.Logger, report *status.ProcessStatus, desiredLRP models.DesiredLRP) bool {
+	violations := p.validator.Validate(desiredLRP)
+	if len(violations) == 0 {
+		return false
+	}
+
+	logger.Error("invalid-desired-lrp", ErrInvalidDesiredLRP, lager.Data{
+		"desired-app-message": desiredLRP,
+		"violations":          violations,
+	})
+	report.Violations = violations
+	report.LastError = ErrInvalidDesiredLRP.Error()
+	p.emitter.IncrementCounter(metrics.InvalidDesiredLRPs)
+
+	return true
+}
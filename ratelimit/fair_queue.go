@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/gunk/timeprovider"
+)
+
+// A Throttle decides whether callers may go ahead now. Callers that may
+// not are not held up; they are expected to try again later.
+type Throttle interface {
+	Take(key string) bool
+}
+
+const grantLifetime = 30 * time.Second
+
+// A FairQueue lets callers go ahead some number of times per second, with
+// bursts of up to a maximum number at once. Once the burst is used up, a
+// key that is refused waits for its turn, and each waiting key gets its
+// turn in order, so that a key that wants to go ahead many times cannot
+// hold up the others. When a key's turn comes, a go-ahead is set aside for
+// it and the key is sent on Ready, so that its caller knows to try again.
+// A go-ahead that is not taken within grantLifetime is put back, so that
+// keys that stop trying are not remembered forever.
+//
+// It is run as an ifrit process; once it is stopped, nobody may go ahead.
+type FairQueue struct {
+	interval     time.Duration
+	burst        int
+	timeProvider timeprovider.TimeProvider
+
+	ready chan string
+
+	tokens  int
+	granted map[string]time.Time
+	waiting map[string]bool
+	turns   []string
+	stopped bool
+	lock    sync.Mutex
+}
+
+func NewFairQueue(perSecond float64, burst int, timeProvider timeprovider.TimeProvider) *FairQueue {
+	return &FairQueue{
+		interval:     time.Duration(float64(time.Second) / perSecond),
+		burst:        burst,
+		timeProvider: timeProvider,
+
+		ready: make(chan string),
+
+		tokens:  burst,
+		granted: map[string]time.Time{},
+		waiting: map[string]bool{},
+	}
+}
+
+func (q *FairQueue) Ready() <-chan string {
+	return q.ready
+}
+
+func (q *FairQueue) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := q.timeProvider.NewTickerChannel("fair-queue", q.interval)
+
+	close(ready)
+
+	var releasing []string
+	for {
+		var readyKeys chan<- string
+		var next string
+		if len(releasing) > 0 {
+			readyKeys = q.ready
+			next = releasing[0]
+		}
+
+		select {
+		case now := <-ticker:
+			q.lock.Lock()
+			if q.tokens < q.burst {
+				q.tokens++
+			}
+			q.expireGrants(now)
+			releasing = append(releasing, q.dispatch(now)...)
+			q.lock.Unlock()
+
+		case readyKeys <- next:
+			releasing = releasing[1:]
+
+		case <-signals:
+			q.stop()
+			return nil
+		}
+	}
+}
+
+func (q *FairQueue) Take(key string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.stopped {
+		return false
+	}
+
+	if _, ok := q.granted[key]; ok {
+		delete(q.granted, key)
+		return true
+	}
+
+	// Tokens are only left over when nobody is waiting for their turn.
+	if q.tokens > 0 {
+		q.tokens--
+		return true
+	}
+
+	if !q.waiting[key] {
+		q.waiting[key] = true
+		q.turns = append(q.turns, key)
+	}
+
+	return false
+}
+
+// dispatch sets aside a go-ahead for as many waiting keys as there are
+// tokens for, in turn, and returns those keys. It must be called with the
+// lock held.
+func (q *FairQueue) dispatch(now time.Time) []string {
+	var released []string
+	for q.tokens > 0 && len(q.turns) > 0 {
+		key := q.turns[0]
+		q.turns = q.turns[1:]
+
+		delete(q.waiting, key)
+		q.granted[key] = now
+		released = append(released, key)
+
+		q.tokens--
+	}
+
+	return released
+}
+
+// expireGrants forgets the go-aheads that were set aside for keys that have
+// not come back for them, and puts their tokens back. It must be called with
+// the lock held.
+func (q *FairQueue) expireGrants(now time.Time) {
+	for key, grantedAt := range q.granted {
+		if q.waiting[key] || now.Sub(grantedAt) < grantLifetime {
+			continue
+		}
+
+		delete(q.granted, key)
+		if q.tokens < q.burst {
+			q.tokens++
+		}
+	}
+}
+
+func (q *FairQueue) stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.stopped = true
+}
@@ -0,0 +1,125 @@
+package ratelimit_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FairQueue", func() {
+	var (
+		timeProvider *faketimeprovider.FakeTimeProvider
+		queue        *FairQueue
+		process      ifrit.Process
+	)
+
+	BeforeEach(func() {
+		timeProvider = faketimeprovider.New(time.Unix(1000, 0))
+		timeProvider.ProvideFakeChannels = true
+
+		queue = NewFairQueue(10, 1, timeProvider)
+
+		process = ifrit.Envoke(queue)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	tickAt := func(now time.Time) {
+		timeProvider.TickerChannelFor("fair-queue") <- now
+	}
+
+	tick := func() {
+		tickAt(time.Now())
+	}
+
+	It("ticks at the rate", func() {
+		Ω(timeProvider.TickerDurationFor("fair-queue")).Should(Equal(100 * time.Millisecond))
+	})
+
+	It("lets a burst go ahead right away", func() {
+		Ω(queue.Take("some-key")).Should(BeTrue())
+	})
+
+	It("refuses callers once the burst is used up, until their turn comes", func() {
+		Ω(queue.Take("some-key")).Should(BeTrue())
+		Ω(queue.Take("some-key")).Should(BeFalse())
+		Consistently(queue.Ready()).ShouldNot(Receive())
+
+		tick()
+		Eventually(queue.Ready()).Should(Receive(Equal("some-key")))
+
+		Ω(queue.Take("some-key")).Should(BeTrue())
+		Ω(queue.Take("some-key")).Should(BeFalse())
+	})
+
+	It("keeps the turn of a key for that key", func() {
+		Ω(queue.Take("some-key")).Should(BeTrue())
+		Ω(queue.Take("some-key")).Should(BeFalse())
+
+		tick()
+		Eventually(queue.Ready()).Should(Receive(Equal("some-key")))
+
+		Ω(queue.Take("some-other-key")).Should(BeFalse())
+		Ω(queue.Take("some-key")).Should(BeTrue())
+	})
+
+	It("gives each waiting key its turn", func() {
+		Ω(queue.Take("big")).Should(BeTrue())
+		Ω(queue.Take("big")).Should(BeFalse())
+		Ω(queue.Take("small")).Should(BeFalse())
+
+		tick()
+		Eventually(queue.Ready()).Should(Receive(Equal("big")))
+		Ω(queue.Take("big")).Should(BeTrue())
+		Ω(queue.Take("big")).Should(BeFalse())
+
+		tick()
+		Eventually(queue.Ready()).Should(Receive(Equal("small")))
+		Ω(queue.Take("small")).Should(BeTrue())
+
+		tick()
+		Eventually(queue.Ready()).Should(Receive(Equal("big")))
+	})
+
+	Context("when a key does not come back for its turn", func() {
+		BeforeEach(func() {
+			Ω(queue.Take("gone")).Should(BeTrue())
+			Ω(queue.Take("gone")).Should(BeFalse())
+
+			tick()
+			Eventually(queue.Ready()).Should(Receive(Equal("gone")))
+		})
+
+		It("keeps the turn for a while", func() {
+			tickAt(time.Now().Add(time.Second))
+
+			Eventually(func() bool { return queue.Take("other") }).Should(BeTrue())
+			Ω(queue.Take("gone")).Should(BeTrue())
+		})
+
+		It("eventually forgets the key and lets another go ahead instead", func() {
+			tickAt(time.Now().Add(time.Hour))
+
+			Eventually(func() bool { return queue.Take("other") }).Should(BeTrue())
+			Ω(queue.Take("gone")).Should(BeFalse())
+		})
+	})
+
+	Context("when stopped", func() {
+		It("lets nobody go ahead", func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+
+			Ω(queue.Take("some-key")).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,53 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/ratelimit"
+)
+
+type FakeThrottle struct {
+	TakeStub        func(key string) bool
+	takeMutex       sync.RWMutex
+	takeArgsForCall []struct {
+		key string
+	}
+	takeReturns struct {
+		result1 bool
+	}
+}
+
+func (fake *FakeThrottle) Take(key string) bool {
+	fake.takeMutex.Lock()
+	defer fake.takeMutex.Unlock()
+	fake.takeArgsForCall = append(fake.takeArgsForCall, struct {
+		key string
+	}{key})
+	if fake.TakeStub != nil {
+		return fake.TakeStub(key)
+	} else {
+		return fake.takeReturns.result1
+	}
+}
+
+func (fake *FakeThrottle) TakeCallCount() int {
+	fake.takeMutex.RLock()
+	defer fake.takeMutex.RUnlock()
+	return len(fake.takeArgsForCall)
+}
+
+func (fake *FakeThrottle) TakeArgsForCall(i int) string {
+	fake.takeMutex.RLock()
+	defer fake.takeMutex.RUnlock()
+	return fake.takeArgsForCall[i].key
+}
+
+func (fake *FakeThrottle) TakeReturns(result1 bool) {
+	fake.TakeStub = nil
+	fake.takeReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+var _ ratelimit.Throttle = new(FakeThrottle)
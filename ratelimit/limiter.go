@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/gunk/timeprovider"
+)
+
+// A Limiter allows some number of events per second, and bursts of up to a
+// maximum number of events at once. It never blocks: events it does not
+// allow are left to be tried again later.
+type Limiter interface {
+	Allow() bool
+}
+
+type tokenBucket struct {
+	perSecond    float64
+	burst        float64
+	timeProvider timeprovider.TimeProvider
+
+	tokens     float64
+	lastFilled time.Time
+	lock       sync.Mutex
+}
+
+func New(perSecond float64, burst int, timeProvider timeprovider.TimeProvider) Limiter {
+	return &tokenBucket{
+		perSecond:    perSecond,
+		burst:        float64(burst),
+		timeProvider: timeProvider,
+
+		tokens:     float64(burst),
+		lastFilled: timeProvider.Time(),
+	}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.timeProvider.Time()
+
+	b.tokens += now.Sub(b.lastFilled).Seconds() * b.perSecond
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFilled = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
@@ -0,0 +1,51 @@
+package ratelimit_test
+
+import (
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/ratelimit"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		timeProvider *faketimeprovider.FakeTimeProvider
+		limiter      Limiter
+	)
+
+	BeforeEach(func() {
+		timeProvider = faketimeprovider.New(time.Unix(1000, 0))
+		limiter = New(2, 3, timeProvider)
+	})
+
+	allowed := func() int {
+		count := 0
+		for limiter.Allow() {
+			count++
+		}
+
+		return count
+	}
+
+	It("allows a burst at first", func() {
+		Ω(allowed()).Should(Equal(3))
+	})
+
+	It("allows events at the rate once the burst is used up", func() {
+		allowed()
+
+		timeProvider.Increment(500 * time.Millisecond)
+		Ω(allowed()).Should(Equal(1))
+
+		timeProvider.Increment(2 * time.Second)
+		Ω(allowed()).Should(Equal(3))
+	})
+
+	It("does not save up more than the burst", func() {
+		timeProvider.Increment(time.Hour)
+		Ω(allowed()).Should(Equal(3))
+	})
+})
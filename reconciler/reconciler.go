@@ -0,0 +1,62 @@
+package reconciler
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+const (
+	DeltaForceStrategy    = "delta-force"
+	ConvergeStrategy      = "converge"
+	PreferRunningStrategy = "prefer-running"
+	SpreadStrategy        = "spread"
+)
+
+// A Reconciler decides which instances of a desired LRP to start and stop to
+// bring its actual LRPs in line with the number of instances desired. Among
+// instances at the same index, the guids to stop are in the order they
+// should be stopped in.
+type Reconciler interface {
+	Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result
+}
+
+// New returns the reconciler for the named strategy.
+func New(strategy string) (Reconciler, error) {
+	switch strategy {
+	case DeltaForceStrategy:
+		return NewDeltaForce(), nil
+	case ConvergeStrategy:
+		return NewConverge(), nil
+	case PreferRunningStrategy:
+		return NewPreferRunning(), nil
+	case SpreadStrategy:
+		return NewSpread(), nil
+	}
+
+	return nil, fmt.Errorf("unknown reconciliation strategy: %q", strategy)
+}
+
+type byDomain struct {
+	reconcilers map[string]Reconciler
+	fallback    Reconciler
+}
+
+// ByDomain reconciles each desired LRP with the reconciler configured for
+// its domain, or with fallback if there is none.
+func ByDomain(reconcilers map[string]Reconciler, fallback Reconciler) Reconciler {
+	return &byDomain{
+		reconcilers: reconcilers,
+		fallback:    fallback,
+	}
+}
+
+func (r *byDomain) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result {
+	reconciler, found := r.reconcilers[desiredLRP.Domain]
+	if !found {
+		reconciler = r.fallback
+	}
+
+	return reconciler.Reconcile(desiredLRP, desiredInstances, actualLRPs)
+}
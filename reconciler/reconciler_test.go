@@ -0,0 +1,57 @@
+package reconciler_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/reconciler"
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reconciler", func() {
+	Describe("New", func() {
+		It("returns a reconciler for each known strategy", func() {
+			for _, strategy := range []string{DeltaForceStrategy, ConvergeStrategy, PreferRunningStrategy, SpreadStrategy} {
+				reconciler, err := New(strategy)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(reconciler).ShouldNot(BeNil())
+			}
+		})
+
+		It("fails for an unknown strategy", func() {
+			_, err := New("bogus")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("ByDomain", func() {
+		var reconciler Reconciler
+
+		actualLRPs := []models.ActualLRP{
+			{InstanceGuid: "a", Index: 0},
+			{InstanceGuid: "b", Index: 2},
+		}
+
+		BeforeEach(func() {
+			reconciler = ByDomain(map[string]Reconciler{
+				"some-domain": NewConverge(),
+			}, NewDeltaForce())
+		})
+
+		It("uses the reconciler for the domain of the desired LRP", func() {
+			result := reconciler.Reconcile(models.DesiredLRP{Domain: "some-domain"}, 2, actualLRPs)
+			Ω(result).Should(Equal(delta_force.Result{
+				IndicesToStart: []int{1},
+				GuidsToStop:    []string{"b"},
+			}))
+		})
+
+		It("falls back for other domains", func() {
+			result := reconciler.Reconcile(models.DesiredLRP{Domain: "some-other-domain"}, 2, actualLRPs)
+			Ω(result).Should(Equal(delta_force.Result{
+				IndicesToStart: []int{1},
+			}))
+		})
+	})
+})
@@ -0,0 +1,221 @@
+package reconciler
+
+import (
+	"sort"
+
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type deltaForce struct{}
+
+// NewDeltaForce reconciles with delta_force: nothing is stopped while any
+// index is missing, and duplicates are left to a stop auction to resolve.
+// Extra instances are stopped highest index first.
+func NewDeltaForce() Reconciler {
+	return deltaForce{}
+}
+
+func (deltaForce) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result {
+	actualInstances := delta_force.ActualInstances{}
+	for _, actual := range actualLRPs {
+		actualInstances = append(actualInstances, delta_force.ActualInstance{Index: actual.Index, Guid: actual.InstanceGuid})
+	}
+
+	result := delta_force.Reconcile(desiredInstances, actualInstances)
+	highestIndexFirst(result.GuidsToStop, actualLRPs)
+
+	return result
+}
+
+type converge struct{}
+
+// NewConverge starts missing indices and stops extra ones in the same pass,
+// instead of waiting for every index to be present before stopping anything.
+// Extra instances are stopped highest index first.
+func NewConverge() Reconciler {
+	return converge{}
+}
+
+func (converge) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result {
+	result := delta_force.Result{
+		IndicesToStart: missingIndices(desiredInstances, actualLRPs),
+		GuidsToStop:    extraGuids(desiredInstances, actualLRPs),
+	}
+	highestIndexFirst(result.GuidsToStop, actualLRPs)
+
+	byIndex := actualsByIndex(actualLRPs)
+	for index := 0; index < desiredInstances; index++ {
+		if len(byIndex[index]) > 1 {
+			result.IndicesToStopAllButOne = append(result.IndicesToStopAllButOne, index)
+		}
+	}
+
+	return result
+}
+
+type preferRunning struct{}
+
+// NewPreferRunning resolves duplicates itself, keeping the instance that has
+// been running longest and stopping ones that are still starting first.
+func NewPreferRunning() Reconciler {
+	return preferRunning{}
+}
+
+func (preferRunning) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result {
+	return deduplicate(desiredInstances, actualLRPs, func(a, b models.ActualLRP) int {
+		aRunning := a.State == models.ActualLRPStateRunning
+		bRunning := b.State == models.ActualLRPStateRunning
+
+		switch {
+		case aRunning && !bRunning:
+			return -1
+		case bRunning && !aRunning:
+			return 1
+		case a.Since < b.Since:
+			return -1
+		case b.Since < a.Since:
+			return 1
+		}
+
+		return 0
+	})
+}
+
+type spread struct{}
+
+// NewSpread resolves duplicates itself, stopping the instances on the
+// executors that run the most instances of the process.
+func NewSpread() Reconciler {
+	return spread{}
+}
+
+func (spread) Reconcile(desiredLRP models.DesiredLRP, desiredInstances int, actualLRPs []models.ActualLRP) delta_force.Result {
+	perExecutor := map[string]int{}
+	for _, actual := range actualLRPs {
+		perExecutor[actual.ExecutorID]++
+	}
+
+	return deduplicate(desiredInstances, actualLRPs, func(a, b models.ActualLRP) int {
+		return perExecutor[a.ExecutorID] - perExecutor[b.ExecutorID]
+	})
+}
+
+// deduplicate reconciles like delta_force, except that it picks which
+// instances to stop itself. compare orders instances by how much they are
+// worth keeping, negative meaning a is worth more than b. Extra instances
+// are stopped least valuable first. Of the instances at a duplicated index
+// the most valuable is kept; if several are equally valuable, the others
+// are stopped and a stop auction is left to choose between those once they
+// are all that remain.
+func deduplicate(desiredInstances int, actualLRPs []models.ActualLRP, compare func(a, b models.ActualLRP) int) delta_force.Result {
+	result := delta_force.Result{
+		IndicesToStart: missingIndices(desiredInstances, actualLRPs),
+	}
+
+	if len(result.IndicesToStart) > 0 {
+		return result
+	}
+
+	extra := byPreference{compare: compare}
+	for _, actual := range actualLRPs {
+		if actual.Index >= desiredInstances {
+			extra.actuals = append(extra.actuals, actual)
+		}
+	}
+
+	sort.Stable(sort.Reverse(extra))
+	for _, actual := range extra.actuals {
+		result.GuidsToStop = append(result.GuidsToStop, actual.InstanceGuid)
+	}
+
+	byIndex := actualsByIndex(actualLRPs)
+	for index := 0; index < desiredInstances; index++ {
+		duplicates := byPreference{actuals: byIndex[index], compare: compare}
+		if len(duplicates.actuals) < 2 {
+			continue
+		}
+
+		sort.Stable(duplicates)
+
+		best := duplicates.actuals[0]
+		tied := 1
+		for _, actual := range duplicates.actuals[1:] {
+			if compare(actual, best) == 0 {
+				tied++
+			}
+		}
+
+		if tied == len(duplicates.actuals) {
+			result.IndicesToStopAllButOne = append(result.IndicesToStopAllButOne, index)
+			continue
+		}
+
+		for _, actual := range duplicates.actuals[tied:] {
+			result.GuidsToStop = append(result.GuidsToStop, actual.InstanceGuid)
+		}
+	}
+
+	return result
+}
+
+type byPreference struct {
+	actuals []models.ActualLRP
+	compare func(a, b models.ActualLRP) int
+}
+
+func (p byPreference) Len() int           { return len(p.actuals) }
+func (p byPreference) Less(i, j int) bool { return p.compare(p.actuals[i], p.actuals[j]) < 0 }
+func (p byPreference) Swap(i, j int)      { p.actuals[i], p.actuals[j] = p.actuals[j], p.actuals[i] }
+func missingIndices(desiredInstances int, actualLRPs []models.ActualLRP) []int {
+	byIndex := actualsByIndex(actualLRPs)
+
+	var missing []int
+	for index := 0; index < desiredInstances; index++ {
+		if len(byIndex[index]) == 0 {
+			missing = append(missing, index)
+		}
+	}
+
+	return missing
+}
+
+func extraGuids(desiredInstances int, actualLRPs []models.ActualLRP) []string {
+	var extra []string
+	for _, actual := range actualLRPs {
+		if actual.Index >= desiredInstances {
+			extra = append(extra, actual.InstanceGuid)
+		}
+	}
+
+	return extra
+}
+
+// highestIndexFirst sorts guids by the index of their instance, highest
+// first, so that scaling down removes instances from the top.
+func highestIndexFirst(guids []string, actualLRPs []models.ActualLRP) {
+	indices := map[string]int{}
+	for _, actual := range actualLRPs {
+		indices[actual.InstanceGuid] = actual.Index
+	}
+
+	sort.Stable(byIndexDescending{guids: guids, indices: indices})
+}
+
+type byIndexDescending struct {
+	guids   []string
+	indices map[string]int
+}
+
+func (b byIndexDescending) Len() int           { return len(b.guids) }
+func (b byIndexDescending) Less(i, j int) bool { return b.indices[b.guids[i]] > b.indices[b.guids[j]] }
+func (b byIndexDescending) Swap(i, j int)      { b.guids[i], b.guids[j] = b.guids[j], b.guids[i] }
+
+func actualsByIndex(actualLRPs []models.ActualLRP) map[int][]models.ActualLRP {
+	byIndex := map[int][]models.ActualLRP{}
+	for _, actual := range actualLRPs {
+		byIndex[actual.Index] = append(byIndex[actual.Index], actual)
+	}
+
+	return byIndex
+}
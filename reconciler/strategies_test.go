@@ -0,0 +1,159 @@
+package reconciler_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/reconciler"
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Strategies", func() {
+	var desiredLRP models.DesiredLRP
+
+	BeforeEach(func() {
+		desiredLRP = models.DesiredLRP{ProcessGuid: "some-process-guid"}
+	})
+
+	Describe("DeltaForce", func() {
+		It("reconciles like delta_force", func() {
+			result := NewDeltaForce().Reconcile(desiredLRP, 2, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0},
+				{InstanceGuid: "b", Index: 0},
+				{InstanceGuid: "c", Index: 1},
+				{InstanceGuid: "d", Index: 2},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				GuidsToStop:            []string{"d"},
+				IndicesToStopAllButOne: []int{0},
+			}))
+		})
+
+		It("stops extra instances highest index first", func() {
+			result := NewDeltaForce().Reconcile(desiredLRP, 1, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0},
+				{InstanceGuid: "b", Index: 1},
+				{InstanceGuid: "c", Index: 3},
+				{InstanceGuid: "d", Index: 2},
+			})
+
+			Ω(result.GuidsToStop).Should(Equal([]string{"c", "d", "b"}))
+		})
+	})
+
+	Describe("Converge", func() {
+		It("starts missing indices and stops extra instances at once", func() {
+			result := NewConverge().Reconcile(desiredLRP, 3, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0},
+				{InstanceGuid: "b", Index: 0},
+				{InstanceGuid: "c", Index: 3},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				IndicesToStart:         []int{1, 2},
+				GuidsToStop:            []string{"c"},
+				IndicesToStopAllButOne: []int{0},
+			}))
+		})
+
+		It("stops extra instances highest index first", func() {
+			result := NewConverge().Reconcile(desiredLRP, 1, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 1},
+				{InstanceGuid: "b", Index: 3},
+				{InstanceGuid: "c", Index: 2},
+			})
+
+			Ω(result.GuidsToStop).Should(Equal([]string{"b", "c", "a"}))
+		})
+	})
+
+	Describe("PreferRunning", func() {
+		It("keeps a running duplicate over a starting one", func() {
+			result := NewPreferRunning().Reconcile(desiredLRP, 1, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateStarting, Since: 1},
+				{InstanceGuid: "b", Index: 0, State: models.ActualLRPStateRunning, Since: 2},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				GuidsToStop: []string{"a"},
+			}))
+		})
+
+		It("keeps the duplicate that has been running longest", func() {
+			result := NewPreferRunning().Reconcile(desiredLRP, 1, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateRunning, Since: 3},
+				{InstanceGuid: "b", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+				{InstanceGuid: "c", Index: 0, State: models.ActualLRPStateRunning, Since: 2},
+			})
+
+			Ω(result.GuidsToStop).Should(ConsistOf("a", "c"))
+		})
+
+		It("stops starting extra instances before running ones, and newer before older", func() {
+			result := NewPreferRunning().Reconcile(desiredLRP, 1, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+				{InstanceGuid: "b", Index: 1, State: models.ActualLRPStateRunning, Since: 1},
+				{InstanceGuid: "c", Index: 2, State: models.ActualLRPStateRunning, Since: 2},
+				{InstanceGuid: "d", Index: 3, State: models.ActualLRPStateStarting, Since: 3},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				GuidsToStop: []string{"d", "c", "b"},
+			}))
+		})
+
+		Context("when duplicates are equally worth keeping", func() {
+			It("falls back to a stop auction", func() {
+				result := NewPreferRunning().Reconcile(desiredLRP, 1, []models.ActualLRP{
+					{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+					{InstanceGuid: "b", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+				})
+
+				Ω(result).Should(Equal(delta_force.Result{
+					IndicesToStopAllButOne: []int{0},
+				}))
+			})
+
+			It("stops the less valuable duplicates first", func() {
+				result := NewPreferRunning().Reconcile(desiredLRP, 1, []models.ActualLRP{
+					{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+					{InstanceGuid: "b", Index: 0, State: models.ActualLRPStateStarting, Since: 2},
+					{InstanceGuid: "c", Index: 0, State: models.ActualLRPStateRunning, Since: 1},
+				})
+
+				Ω(result).Should(Equal(delta_force.Result{
+					GuidsToStop: []string{"b"},
+				}))
+			})
+		})
+
+		It("stops nothing while an index is missing", func() {
+			result := NewPreferRunning().Reconcile(desiredLRP, 2, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0, State: models.ActualLRPStateStarting},
+				{InstanceGuid: "b", Index: 0, State: models.ActualLRPStateRunning},
+				{InstanceGuid: "c", Index: 2, State: models.ActualLRPStateRunning},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				IndicesToStart: []int{1},
+			}))
+		})
+	})
+
+	Describe("Spread", func() {
+		It("stops the duplicates on the most packed executors", func() {
+			result := NewSpread().Reconcile(desiredLRP, 2, []models.ActualLRP{
+				{InstanceGuid: "a", Index: 0, ExecutorID: "executor-1"},
+				{InstanceGuid: "b", Index: 1, ExecutorID: "executor-1"},
+				{InstanceGuid: "c", Index: 0, ExecutorID: "executor-2"},
+				{InstanceGuid: "d", Index: 2, ExecutorID: "executor-1"},
+			})
+
+			Ω(result).Should(Equal(delta_force.Result{
+				GuidsToStop: []string{"d", "a"},
+			}))
+		})
+	})
+})
@@ -0,0 +1,143 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/restartpolicy"
+)
+
+type FakeRestartPolicy struct {
+	DecideStub        func(processGuid string, index int) restartpolicy.Decision
+	decideMutex       sync.RWMutex
+	decideArgsForCall []struct {
+		processGuid string
+		index       int
+	}
+	decideReturns struct {
+		result1 restartpolicy.Decision
+	}
+	RecordStartStub        func(processGuid string, index int)
+	recordStartMutex       sync.RWMutex
+	recordStartArgsForCall []struct {
+		processGuid string
+		index       int
+	}
+	RecordStopStub        func(processGuid string, index int)
+	recordStopMutex       sync.RWMutex
+	recordStopArgsForCall []struct {
+		processGuid string
+		index       int
+	}
+	ForgetStub        func(processGuid string)
+	forgetMutex       sync.RWMutex
+	forgetArgsForCall []struct {
+		processGuid string
+	}
+}
+
+func (fake *FakeRestartPolicy) Decide(processGuid string, index int) restartpolicy.Decision {
+	fake.decideMutex.Lock()
+	defer fake.decideMutex.Unlock()
+	fake.decideArgsForCall = append(fake.decideArgsForCall, struct {
+		processGuid string
+		index       int
+	}{processGuid, index})
+	if fake.DecideStub != nil {
+		return fake.DecideStub(processGuid, index)
+	} else {
+		return fake.decideReturns.result1
+	}
+}
+
+func (fake *FakeRestartPolicy) DecideCallCount() int {
+	fake.decideMutex.RLock()
+	defer fake.decideMutex.RUnlock()
+	return len(fake.decideArgsForCall)
+}
+
+func (fake *FakeRestartPolicy) DecideArgsForCall(i int) (string, int) {
+	fake.decideMutex.RLock()
+	defer fake.decideMutex.RUnlock()
+	return fake.decideArgsForCall[i].processGuid, fake.decideArgsForCall[i].index
+}
+
+func (fake *FakeRestartPolicy) DecideReturns(result1 restartpolicy.Decision) {
+	fake.DecideStub = nil
+	fake.decideReturns = struct {
+		result1 restartpolicy.Decision
+	}{result1}
+}
+
+func (fake *FakeRestartPolicy) RecordStart(processGuid string, index int) {
+	fake.recordStartMutex.Lock()
+	defer fake.recordStartMutex.Unlock()
+	fake.recordStartArgsForCall = append(fake.recordStartArgsForCall, struct {
+		processGuid string
+		index       int
+	}{processGuid, index})
+	if fake.RecordStartStub != nil {
+		fake.RecordStartStub(processGuid, index)
+	}
+}
+
+func (fake *FakeRestartPolicy) RecordStartCallCount() int {
+	fake.recordStartMutex.RLock()
+	defer fake.recordStartMutex.RUnlock()
+	return len(fake.recordStartArgsForCall)
+}
+
+func (fake *FakeRestartPolicy) RecordStartArgsForCall(i int) (string, int) {
+	fake.recordStartMutex.RLock()
+	defer fake.recordStartMutex.RUnlock()
+	return fake.recordStartArgsForCall[i].processGuid, fake.recordStartArgsForCall[i].index
+}
+
+func (fake *FakeRestartPolicy) RecordStop(processGuid string, index int) {
+	fake.recordStopMutex.Lock()
+	defer fake.recordStopMutex.Unlock()
+	fake.recordStopArgsForCall = append(fake.recordStopArgsForCall, struct {
+		processGuid string
+		index       int
+	}{processGuid, index})
+	if fake.RecordStopStub != nil {
+		fake.RecordStopStub(processGuid, index)
+	}
+}
+
+func (fake *FakeRestartPolicy) RecordStopCallCount() int {
+	fake.recordStopMutex.RLock()
+	defer fake.recordStopMutex.RUnlock()
+	return len(fake.recordStopArgsForCall)
+}
+
+func (fake *FakeRestartPolicy) RecordStopArgsForCall(i int) (string, int) {
+	fake.recordStopMutex.RLock()
+	defer fake.recordStopMutex.RUnlock()
+	return fake.recordStopArgsForCall[i].processGuid, fake.recordStopArgsForCall[i].index
+}
+
+func (fake *FakeRestartPolicy) Forget(processGuid string) {
+	fake.forgetMutex.Lock()
+	defer fake.forgetMutex.Unlock()
+	fake.forgetArgsForCall = append(fake.forgetArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	if fake.ForgetStub != nil {
+		fake.ForgetStub(processGuid)
+	}
+}
+
+func (fake *FakeRestartPolicy) ForgetCallCount() int {
+	fake.forgetMutex.RLock()
+	defer fake.forgetMutex.RUnlock()
+	return len(fake.forgetArgsForCall)
+}
+
+func (fake *FakeRestartPolicy) ForgetArgsForCall(i int) string {
+	fake.forgetMutex.RLock()
+	defer fake.forgetMutex.RUnlock()
+	return fake.forgetArgsForCall[i].processGuid
+}
+
+var _ restartpolicy.RestartPolicy = new(FakeRestartPolicy)
@@ -0,0 +1,154 @@
+package restartpolicy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/gunk/timeprovider"
+)
+
+type Decision int
+
+const (
+	Start Decision = iota
+	BackOff
+	GiveUp
+)
+
+// A RestartPolicy decides whether an index of a process may be started. An
+// index that is started again after its last start is taken to have
+// crashed, unless it was stopped on purpose in between.
+type RestartPolicy interface {
+	Decide(processGuid string, index int) Decision
+	RecordStart(processGuid string, index int)
+	RecordStop(processGuid string, index int)
+	Forget(processGuid string)
+}
+
+type instanceKey struct {
+	processGuid string
+	index       int
+}
+
+type instanceHistory struct {
+	crashes       int
+	lastStartedAt time.Time
+	crashCounted  bool
+	restartAt     time.Time
+}
+
+type restartPolicy struct {
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	resetAfter     time.Duration
+	maxCrashes     int
+	timeProvider   timeprovider.TimeProvider
+
+	instances map[instanceKey]*instanceHistory
+	lock      sync.Mutex
+}
+
+// New returns a policy that restarts an index right away after its first
+// crash, then waits initialBackoff, doubling up to maxBackoff for each crash
+// after that. An instance that ran for resetAfter is forgiven its earlier
+// crashes. A maxCrashes of 0 means the policy never gives up.
+func New(
+	initialBackoff time.Duration,
+	maxBackoff time.Duration,
+	resetAfter time.Duration,
+	maxCrashes int,
+	timeProvider timeprovider.TimeProvider,
+) RestartPolicy {
+	return &restartPolicy{
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		resetAfter:     resetAfter,
+		maxCrashes:     maxCrashes,
+		timeProvider:   timeProvider,
+
+		instances: map[instanceKey]*instanceHistory{},
+	}
+}
+
+func (p *restartPolicy) Decide(processGuid string, index int) Decision {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	history, ok := p.instances[instanceKey{processGuid, index}]
+	if !ok {
+		return Start
+	}
+
+	now := p.timeProvider.Time()
+
+	if !history.crashCounted {
+		if now.Sub(history.lastStartedAt) >= p.resetAfter {
+			history.crashes = 0
+		}
+
+		history.crashes++
+		history.crashCounted = true
+		history.restartAt = now.Add(p.backoff(history.crashes))
+	}
+
+	if p.maxCrashes > 0 && history.crashes >= p.maxCrashes {
+		return GiveUp
+	}
+
+	if now.Before(history.restartAt) {
+		return BackOff
+	}
+
+	return Start
+}
+
+func (p *restartPolicy) RecordStart(processGuid string, index int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := instanceKey{processGuid, index}
+
+	history, ok := p.instances[key]
+	if !ok {
+		history = &instanceHistory{}
+		p.instances[key] = history
+	}
+
+	history.lastStartedAt = p.timeProvider.Time()
+	history.crashCounted = false
+}
+
+func (p *restartPolicy) RecordStop(processGuid string, index int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.instances, instanceKey{processGuid, index})
+}
+
+func (p *restartPolicy) Forget(processGuid string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key := range p.instances {
+		if key.processGuid == processGuid {
+			delete(p.instances, key)
+		}
+	}
+}
+
+func (p *restartPolicy) backoff(crashes int) time.Duration {
+	if crashes <= 1 {
+		return 0
+	}
+
+	backoff := p.initialBackoff
+	for i := 2; i < crashes && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > p.maxBackoff {
+		return p.maxBackoff
+	}
+
+	return backoff
+}
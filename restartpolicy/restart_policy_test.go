@@ -0,0 +1,131 @@
+package restartpolicy_test
+
+import (
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/restartpolicy"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestartPolicy", func() {
+	var (
+		timeProvider *faketimeprovider.FakeTimeProvider
+		maxCrashes   int
+		policy       RestartPolicy
+	)
+
+	BeforeEach(func() {
+		timeProvider = faketimeprovider.New(time.Unix(1000, 0))
+		maxCrashes = 0
+	})
+
+	JustBeforeEach(func() {
+		policy = New(10*time.Second, 40*time.Second, 5*time.Minute, maxCrashes, timeProvider)
+	})
+
+	// restartAfterCrash starts the index, lets it crash, and returns how long
+	// the policy made the restart wait.
+	restartAfterCrash := func() time.Duration {
+		policy.RecordStart("some-process-guid", 0)
+
+		waited := time.Duration(0)
+		for policy.Decide("some-process-guid", 0) == BackOff {
+			timeProvider.Increment(time.Second)
+			waited += time.Second
+		}
+
+		return waited
+	}
+
+	It("starts indices that have never been started", func() {
+		Ω(policy.Decide("some-process-guid", 0)).Should(Equal(Start))
+	})
+
+	It("restarts right away after the first crash", func() {
+		Ω(restartAfterCrash()).Should(BeZero())
+	})
+
+	It("backs off exponentially after that, up to the maximum", func() {
+		Ω(restartAfterCrash()).Should(BeZero())
+		Ω(restartAfterCrash()).Should(Equal(10 * time.Second))
+		Ω(restartAfterCrash()).Should(Equal(20 * time.Second))
+		Ω(restartAfterCrash()).Should(Equal(40 * time.Second))
+		Ω(restartAfterCrash()).Should(Equal(40 * time.Second))
+	})
+
+	It("does not count a crash more than once per start", func() {
+		restartAfterCrash()
+		policy.RecordStart("some-process-guid", 0)
+
+		for i := 0; i < 5; i++ {
+			Ω(policy.Decide("some-process-guid", 0)).Should(Equal(BackOff))
+		}
+
+		timeProvider.Increment(10 * time.Second)
+		Ω(policy.Decide("some-process-guid", 0)).Should(Equal(Start))
+	})
+
+	It("keeps indices apart", func() {
+		restartAfterCrash()
+		restartAfterCrash()
+
+		Ω(policy.Decide("some-process-guid", 1)).Should(Equal(Start))
+		Ω(policy.Decide("some-other-process-guid", 0)).Should(Equal(Start))
+	})
+
+	Context("when an instance stays up long enough", func() {
+		It("forgives its earlier crashes", func() {
+			restartAfterCrash()
+			restartAfterCrash()
+
+			policy.RecordStart("some-process-guid", 0)
+			timeProvider.Increment(5 * time.Minute)
+
+			Ω(policy.Decide("some-process-guid", 0)).Should(Equal(Start))
+			Ω(restartAfterCrash()).Should(Equal(10 * time.Second))
+		})
+	})
+
+	Context("when an index is stopped on purpose", func() {
+		It("does not count starting it again as a crash", func() {
+			restartAfterCrash()
+			restartAfterCrash()
+
+			policy.RecordStop("some-process-guid", 0)
+
+			Ω(policy.Decide("some-process-guid", 0)).Should(Equal(Start))
+			Ω(restartAfterCrash()).Should(BeZero())
+		})
+	})
+
+	Context("when a process is forgotten", func() {
+		It("forgets the crashes of all of its indices", func() {
+			restartAfterCrash()
+			restartAfterCrash()
+
+			policy.Forget("some-process-guid")
+
+			Ω(restartAfterCrash()).Should(BeZero())
+		})
+	})
+
+	Context("when there is a maximum number of crashes", func() {
+		BeforeEach(func() {
+			maxCrashes = 3
+		})
+
+		It("gives up once it is reached", func() {
+			restartAfterCrash()
+			restartAfterCrash()
+
+			policy.RecordStart("some-process-guid", 0)
+			Ω(policy.Decide("some-process-guid", 0)).Should(Equal(GiveUp))
+
+			timeProvider.Increment(time.Hour)
+			Ω(policy.Decide("some-process-guid", 0)).Should(Equal(GiveUp))
+		})
+	})
+})
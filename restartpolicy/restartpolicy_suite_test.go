@@ -0,0 +1,13 @@
+package restartpolicy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RestartPolicy Suite")
+}
@@ -0,0 +1,133 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/pivotal-golang/lager"
+)
+
+type Sleeper func(time.Duration)
+
+// retryingBBS retries failed writes to the BBS it wraps, waiting a jittered,
+// exponentially growing time between attempts. Only writes that failed for
+// a reason that may go away are retried; see transient.
+type retryingBBS struct {
+	Bbs.AppManagerBBS
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	sleep          Sleeper
+	emitter        metrics.Emitter
+	logger         lager.Logger
+}
+
+func New(
+	bbs Bbs.AppManagerBBS,
+	maxAttempts int,
+	initialBackoff time.Duration,
+	maxBackoff time.Duration,
+	sleep Sleeper,
+	emitter metrics.Emitter,
+	logger lager.Logger,
+) Bbs.AppManagerBBS {
+	return &retryingBBS{
+		AppManagerBBS: bbs,
+
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		sleep:          sleep,
+		emitter:        emitter,
+		logger:         logger.Session("retry"),
+	}
+}
+
+func (bbs *retryingBBS) RequestLRPStartAuction(lrp models.LRPStartAuction) error {
+	return bbs.retry("request-start-auction", func() error {
+		return bbs.AppManagerBBS.RequestLRPStartAuction(lrp)
+	})
+}
+
+func (bbs *retryingBBS) RequestStopLRPInstance(stopInstance models.StopLRPInstance) error {
+	return bbs.retry("request-stop-instance", func() error {
+		return bbs.AppManagerBBS.RequestStopLRPInstance(stopInstance)
+	})
+}
+
+func (bbs *retryingBBS) RequestLRPStopAuction(stopAuction models.LRPStopAuction) error {
+	return bbs.retry("request-stop-auction", func() error {
+		return bbs.AppManagerBBS.RequestLRPStopAuction(stopAuction)
+	})
+}
+
+func (bbs *retryingBBS) retry(action string, write func() error) error {
+	backoff := bbs.initialBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = write()
+		if err == nil || !transient(err) || attempt >= bbs.maxAttempts {
+			return err
+		}
+
+		bbs.logger.Info("retrying", lager.Data{
+			"action":  action,
+			"attempt": attempt,
+			"error":   err.Error(),
+		})
+		bbs.emitter.IncrementCounter(metrics.BBSWriteRetries)
+
+		bbs.sleep(jitter(backoff))
+
+		backoff *= 2
+		if backoff > bbs.maxBackoff {
+			backoff = bbs.maxBackoff
+		}
+	}
+}
+
+// The etcd error codes for a store that cannot commit writes for the moment,
+// or that could not be reached at all.
+const (
+	etcdRaftInternalError = 300
+	etcdLeaderElection    = 301
+	etcdNotReachable      = 501
+)
+
+// transient reports whether a write that failed with err may succeed if it
+// is tried again: the store timed out or could not be reached, or it was
+// busy electing a leader. Every other error says something about the write
+// that will not change.
+func transient(err error) bool {
+	if err == storeadapter.ErrorTimeout {
+		return true
+	}
+
+	var code int
+	switch etcdErr := err.(type) {
+	case etcd.EtcdError:
+		code = etcdErr.ErrorCode
+	case *etcd.EtcdError:
+		code = etcdErr.ErrorCode
+	}
+
+	return code == etcdRaftInternalError || code == etcdLeaderElection || code == etcdNotReachable
+}
+
+// jitter picks a duration between half of d and d, so that writers that
+// failed together do not all retry together.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,247 @@
+package retry_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/metrics"
+	metrics_fakes "github.com/cloudfoundry-incubator/app-manager/metrics/fakes"
+	. "github.com/cloudfoundry-incubator/app-manager/retry"
+	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fake_bbs"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/storeadapter"
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("RetryingBBS", func() {
+	var (
+		bbs     *fake_bbs.FakeAppManagerBBS
+		emitter *metrics_fakes.FakeEmitter
+		logger  *lagertest.TestLogger
+		sleeps  []time.Duration
+
+		retryingBBS Bbs.AppManagerBBS
+	)
+
+	startAuction := models.LRPStartAuction{
+		DesiredLRP:   models.DesiredLRP{ProcessGuid: "some-process-guid"},
+		Index:        1,
+		InstanceGuid: "some-instance-guid",
+	}
+
+	notReachable := &etcd.EtcdError{ErrorCode: 501, Message: "All the given peers are not reachable"}
+
+	// failingTimes returns a write stub that fails the first n attempts with
+	// err, and counts every attempt.
+	failingTimes := func(n int, err error, attempts *int) func() error {
+		return func() error {
+			*attempts++
+			if *attempts <= n {
+				return err
+			}
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		bbs = fake_bbs.NewFakeAppManagerBBS()
+		emitter = metrics_fakes.NewFakeEmitter()
+		logger = lagertest.NewTestLogger("test")
+		sleeps = nil
+
+		sleep := func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		}
+
+		retryingBBS = New(bbs, 4, 100*time.Millisecond, 300*time.Millisecond, sleep, emitter, logger)
+	})
+
+	It("reads from the wrapped BBS", func() {
+		bbs.ActualLRPs = []models.ActualLRP{
+			{ProcessGuid: "some-process-guid", InstanceGuid: "some-instance-guid"},
+		}
+
+		actualLRPs, err := retryingBBS.GetActualLRPsByProcessGuid("some-process-guid")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(actualLRPs).Should(Equal(bbs.ActualLRPs))
+	})
+
+	Context("when a write succeeds", func() {
+		It("does not retry it", func() {
+			err := retryingBBS.RequestLRPStartAuction(startAuction)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(bbs.GetLRPStartAuctions()).Should(HaveLen(1))
+			Ω(sleeps).Should(BeEmpty())
+			Ω(emitter.Counter(metrics.BBSWriteRetries)).Should(BeZero())
+		})
+	})
+
+	Context("when a write fails for a while", func() {
+		var attempts int
+
+		BeforeEach(func() {
+			attempts = 0
+			write := failingTimes(2, storeadapter.ErrorTimeout, &attempts)
+
+			bbs.WhenRequestingLRPStartAuctions = func(models.LRPStartAuction) error {
+				return write()
+			}
+		})
+
+		It("retries it until it succeeds", func() {
+			err := retryingBBS.RequestLRPStartAuction(startAuction)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(attempts).Should(Equal(3))
+		})
+
+		It("backs off between attempts, with jitter", func() {
+			retryingBBS.RequestLRPStartAuction(startAuction)
+
+			Ω(sleeps).Should(HaveLen(2))
+			Ω(sleeps[0]).Should(BeNumerically(">=", 50*time.Millisecond))
+			Ω(sleeps[0]).Should(BeNumerically("<=", 100*time.Millisecond))
+			Ω(sleeps[1]).Should(BeNumerically(">=", 100*time.Millisecond))
+			Ω(sleeps[1]).Should(BeNumerically("<=", 200*time.Millisecond))
+		})
+
+		It("logs and counts the retries", func() {
+			retryingBBS.RequestLRPStartAuction(startAuction)
+
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.retry.retrying"))
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say(`"action":"request-start-auction"`))
+			Ω(emitter.Counter(metrics.BBSWriteRetries)).Should(Equal(2))
+		})
+	})
+
+	Context("when a write keeps failing", func() {
+		var attempts int
+
+		BeforeEach(func() {
+			attempts = 0
+			write := failingTimes(10, storeadapter.ErrorTimeout, &attempts)
+
+			bbs.WhenRequestingLRPStopAuctions = func(models.LRPStopAuction) error {
+				return write()
+			}
+		})
+
+		It("gives up after the maximum number of attempts", func() {
+			err := retryingBBS.RequestLRPStopAuction(models.LRPStopAuction{
+				ProcessGuid: "some-process-guid",
+				Index:       1,
+			})
+			Ω(err).Should(Equal(storeadapter.ErrorTimeout))
+
+			Ω(attempts).Should(Equal(4))
+		})
+
+		It("does not back off for longer than the maximum", func() {
+			retryingBBS.RequestLRPStopAuction(models.LRPStopAuction{
+				ProcessGuid: "some-process-guid",
+				Index:       1,
+			})
+
+			Ω(sleeps).Should(HaveLen(3))
+			Ω(sleeps[2]).Should(BeNumerically(">=", 150*time.Millisecond))
+			Ω(sleeps[2]).Should(BeNumerically("<=", 300*time.Millisecond))
+		})
+	})
+
+	Context("when the store is electing a leader", func() {
+		var attempts int
+
+		BeforeEach(func() {
+			attempts = 0
+			write := failingTimes(1, &etcd.EtcdError{ErrorCode: 301, Message: "During Leader Election"}, &attempts)
+
+			bbs.WhenRequestingLRPStartAuctions = func(models.LRPStartAuction) error {
+				return write()
+			}
+		})
+
+		It("retries the write", func() {
+			err := retryingBBS.RequestLRPStartAuction(startAuction)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(attempts).Should(Equal(2))
+		})
+	})
+
+	Context("when the store can not be reached", func() {
+		var attempts int
+
+		BeforeEach(func() {
+			attempts = 0
+			write := failingTimes(1, notReachable, &attempts)
+
+			bbs.WhenRequestingLRPStartAuctions = func(models.LRPStartAuction) error {
+				return write()
+			}
+		})
+
+		It("retries the write", func() {
+			err := retryingBBS.RequestLRPStartAuction(startAuction)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(attempts).Should(Equal(2))
+		})
+	})
+
+	for _, permanentErr := range []error{
+		storeadapter.ErrorKeyExists,
+		storeadapter.ErrorKeyComparisonFailed,
+		storeadapter.ErrorNodeIsDirectory,
+		storeadapter.ErrorInvalidTTL,
+		storeadapter.ErrorInvalidFormat,
+		errors.New("something unexpected"),
+	} {
+		permanentErr := permanentErr
+
+		Context("when a write fails with "+permanentErr.Error(), func() {
+			var attempts int
+
+			BeforeEach(func() {
+				attempts = 0
+				write := failingTimes(10, permanentErr, &attempts)
+
+				bbs.WhenRequestingLRPStartAuctions = func(models.LRPStartAuction) error {
+					return write()
+				}
+			})
+
+			It("does not retry it", func() {
+				err := retryingBBS.RequestLRPStartAuction(startAuction)
+				Ω(err).Should(Equal(permanentErr))
+
+				Ω(attempts).Should(Equal(1))
+				Ω(sleeps).Should(BeEmpty())
+			})
+		})
+	}
+
+	Context("when stopping an instance fails", func() {
+		BeforeEach(func() {
+			bbs.StopLRPInstanceErr = storeadapter.ErrorTimeout
+		})
+
+		It("retries it", func() {
+			err := retryingBBS.RequestStopLRPInstance(models.StopLRPInstance{
+				ProcessGuid:  "some-process-guid",
+				Index:        1,
+				InstanceGuid: "some-instance-guid",
+			})
+			Ω(err).Should(HaveOccurred())
+
+			Ω(sleeps).Should(HaveLen(3))
+			Ω(emitter.Counter(metrics.BBSWriteRetries)).Should(Equal(3))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/stackcache"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FakeBBS struct {
+	GetAllExecutorsStub        func() ([]models.ExecutorPresence, error)
+	getAllExecutorsMutex       sync.RWMutex
+	getAllExecutorsArgsForCall []struct{}
+	getAllExecutorsReturns     struct {
+		result1 []models.ExecutorPresence
+		result2 error
+	}
+}
+
+func (fake *FakeBBS) GetAllExecutors() ([]models.ExecutorPresence, error) {
+	fake.getAllExecutorsMutex.Lock()
+	defer fake.getAllExecutorsMutex.Unlock()
+	fake.getAllExecutorsArgsForCall = append(fake.getAllExecutorsArgsForCall, struct{}{})
+	if fake.GetAllExecutorsStub != nil {
+		return fake.GetAllExecutorsStub()
+	} else {
+		return fake.getAllExecutorsReturns.result1, fake.getAllExecutorsReturns.result2
+	}
+}
+
+func (fake *FakeBBS) GetAllExecutorsCallCount() int {
+	fake.getAllExecutorsMutex.RLock()
+	defer fake.getAllExecutorsMutex.RUnlock()
+	return len(fake.getAllExecutorsArgsForCall)
+}
+
+func (fake *FakeBBS) GetAllExecutorsReturns(result1 []models.ExecutorPresence, result2 error) {
+	fake.getAllExecutorsMutex.Lock()
+	defer fake.getAllExecutorsMutex.Unlock()
+	fake.GetAllExecutorsStub = nil
+	fake.getAllExecutorsReturns = struct {
+		result1 []models.ExecutorPresence
+		result2 error
+	}{result1, result2}
+}
+
+var _ stackcache.BBS = new(FakeBBS)
@@ -0,0 +1,83 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/app-manager/stackcache"
+)
+
+type FakeHolder struct {
+	HoldStub        func(processGuid string, stack string) bool
+	holdMutex       sync.RWMutex
+	holdArgsForCall []struct {
+		processGuid string
+		stack       string
+	}
+	holdReturns struct {
+		result1 bool
+	}
+	ReleaseStub        func(processGuid string)
+	releaseMutex       sync.RWMutex
+	releaseArgsForCall []struct {
+		processGuid string
+	}
+}
+
+func (fake *FakeHolder) Hold(processGuid string, stack string) bool {
+	fake.holdMutex.Lock()
+	defer fake.holdMutex.Unlock()
+	fake.holdArgsForCall = append(fake.holdArgsForCall, struct {
+		processGuid string
+		stack       string
+	}{processGuid, stack})
+	if fake.HoldStub != nil {
+		return fake.HoldStub(processGuid, stack)
+	} else {
+		return fake.holdReturns.result1
+	}
+}
+
+func (fake *FakeHolder) HoldCallCount() int {
+	fake.holdMutex.RLock()
+	defer fake.holdMutex.RUnlock()
+	return len(fake.holdArgsForCall)
+}
+
+func (fake *FakeHolder) HoldArgsForCall(i int) (string, string) {
+	fake.holdMutex.RLock()
+	defer fake.holdMutex.RUnlock()
+	return fake.holdArgsForCall[i].processGuid, fake.holdArgsForCall[i].stack
+}
+
+func (fake *FakeHolder) HoldReturns(result1 bool) {
+	fake.HoldStub = nil
+	fake.holdReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeHolder) Release(processGuid string) {
+	fake.releaseMutex.Lock()
+	defer fake.releaseMutex.Unlock()
+	fake.releaseArgsForCall = append(fake.releaseArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	if fake.ReleaseStub != nil {
+		fake.ReleaseStub(processGuid)
+	}
+}
+
+func (fake *FakeHolder) ReleaseCallCount() int {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return len(fake.releaseArgsForCall)
+}
+
+func (fake *FakeHolder) ReleaseArgsForCall(i int) string {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return fake.releaseArgsForCall[i].processGuid
+}
+
+var _ stackcache.Holder = new(FakeHolder)
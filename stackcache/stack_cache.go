@@ -0,0 +1,140 @@
+package stackcache
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider"
+	"github.com/pivotal-golang/lager"
+)
+
+type BBS interface {
+	GetAllExecutors() ([]models.ExecutorPresence, error)
+}
+
+// A Holder holds processes back until an executor can run their stack.
+type Holder interface {
+	// Hold reports whether no executor runs the stack, in which case the
+	// process is held until one does.
+	Hold(processGuid string, stack string) bool
+	Release(processGuid string)
+}
+
+// A StackCache knows which stacks the executors run, refreshing them from
+// their presences on an interval. The guids of held processes are sent on
+// Schedulable once an executor runs their stack.
+//
+// Until it has seen the executors once, it holds nothing.
+type StackCache struct {
+	bbs             BBS
+	refreshInterval time.Duration
+	timeProvider    timeprovider.TimeProvider
+	logger          lager.Logger
+
+	schedulable chan string
+
+	stacks map[string]bool
+	held   map[string]string
+	lock   sync.Mutex
+}
+
+func New(bbs BBS, refreshInterval time.Duration, timeProvider timeprovider.TimeProvider, logger lager.Logger) *StackCache {
+	return &StackCache{
+		bbs:             bbs,
+		refreshInterval: refreshInterval,
+		timeProvider:    timeProvider,
+		logger:          logger.Session("stack-cache"),
+
+		schedulable: make(chan string),
+
+		held: map[string]string{},
+	}
+}
+
+func (c *StackCache) Schedulable() <-chan string {
+	return c.schedulable
+}
+
+func (c *StackCache) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := c.timeProvider.NewTickerChannel("stack-cache", c.refreshInterval)
+
+	releasing := c.refresh()
+
+	close(ready)
+
+	for {
+		var schedulable chan<- string
+		var next string
+		if len(releasing) > 0 {
+			schedulable = c.schedulable
+			next = releasing[0]
+		}
+
+		select {
+		case <-ticker:
+			releasing = append(releasing, c.refresh()...)
+
+		case schedulable <- next:
+			releasing = releasing[1:]
+
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (c *StackCache) Hold(processGuid string, stack string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stacks == nil || c.stacks[stack] {
+		delete(c.held, processGuid)
+		return false
+	}
+
+	c.held[processGuid] = stack
+	return true
+}
+
+func (c *StackCache) Release(processGuid string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.held, processGuid)
+}
+
+// refresh returns the held processes whose stack has appeared, and stops
+// holding them.
+func (c *StackCache) refresh() []string {
+	executors, err := c.bbs.GetAllExecutors()
+	if err != nil {
+		c.logger.Error("fetch-executors-failed", err)
+		return nil
+	}
+
+	stacks := map[string]bool{}
+	for _, executor := range executors {
+		stacks[executor.Stack] = true
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stacks = stacks
+
+	var releasing []string
+	for processGuid, stack := range c.held {
+		if stacks[stack] {
+			c.logger.Info("stack-appeared", lager.Data{
+				"process-guid": processGuid,
+				"stack":        stack,
+			})
+			releasing = append(releasing, processGuid)
+			delete(c.held, processGuid)
+		}
+	}
+
+	return releasing
+}
@@ -0,0 +1,125 @@
+package stackcache_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/app-manager/stackcache"
+	"github.com/cloudfoundry-incubator/app-manager/stackcache/fakes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/timeprovider/faketimeprovider"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("StackCache", func() {
+	var (
+		bbs          *fakes.FakeBBS
+		timeProvider *faketimeprovider.FakeTimeProvider
+		logger       *lagertest.TestLogger
+
+		cache   *StackCache
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		bbs = new(fakes.FakeBBS)
+		bbs.GetAllExecutorsReturns([]models.ExecutorPresence{
+			{ExecutorID: "executor-1", Stack: "some-stack"},
+		}, nil)
+
+		timeProvider = faketimeprovider.New(time.Unix(1000, 0))
+		timeProvider.ProvideFakeChannels = true
+
+		logger = lagertest.NewTestLogger("test")
+
+		cache = New(bbs, 10*time.Second, timeProvider, logger)
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Envoke(cache)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	refresh := func() {
+		timeProvider.TickerChannelFor("stack-cache") <- time.Now()
+	}
+
+	It("refreshes on the interval", func() {
+		Ω(timeProvider.TickerDurationFor("stack-cache")).Should(Equal(10 * time.Second))
+
+		refresh()
+		Eventually(bbs.GetAllExecutorsCallCount).Should(Equal(2))
+	})
+
+	It("does not hold processes whose stack an executor runs", func() {
+		Ω(cache.Hold("some-process-guid", "some-stack")).Should(BeFalse())
+	})
+
+	It("holds processes whose stack no executor runs", func() {
+		Ω(cache.Hold("some-process-guid", "some-other-stack")).Should(BeTrue())
+	})
+
+	Context("when an executor with the stack of a held process appears", func() {
+		JustBeforeEach(func() {
+			cache.Hold("some-process-guid", "some-other-stack")
+
+			bbs.GetAllExecutorsReturns([]models.ExecutorPresence{
+				{ExecutorID: "executor-1", Stack: "some-stack"},
+				{ExecutorID: "executor-2", Stack: "some-other-stack"},
+			}, nil)
+
+			refresh()
+		})
+
+		It("says the process is schedulable", func() {
+			Eventually(cache.Schedulable()).Should(Receive(Equal("some-process-guid")))
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.stack-cache.stack-appeared"))
+		})
+
+		It("stops holding it", func() {
+			Eventually(cache.Schedulable()).Should(Receive())
+			Ω(cache.Hold("some-process-guid", "some-other-stack")).Should(BeFalse())
+		})
+	})
+
+	Context("when a held process is released", func() {
+		JustBeforeEach(func() {
+			cache.Hold("some-process-guid", "some-other-stack")
+			cache.Release("some-process-guid")
+
+			bbs.GetAllExecutorsReturns([]models.ExecutorPresence{
+				{ExecutorID: "executor-2", Stack: "some-other-stack"},
+			}, nil)
+
+			refresh()
+		})
+
+		It("does not say it is schedulable", func() {
+			Consistently(cache.Schedulable()).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the executors cannot be fetched", func() {
+		BeforeEach(func() {
+			bbs.GetAllExecutorsReturns(nil, errors.New("oops"))
+		})
+
+		It("logs an error", func() {
+			Ω(logger.TestSink.Buffer).Should(gbytes.Say("test.stack-cache.fetch-executors-failed"))
+		})
+
+		It("does not hold anything until it has seen the executors", func() {
+			Ω(cache.Hold("some-process-guid", "some-other-stack")).Should(BeFalse())
+		})
+	})
+})
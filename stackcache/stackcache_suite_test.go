@@ -0,0 +1,13 @@
+package stackcache_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestStackCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "StackCache Suite")
+}
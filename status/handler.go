@@ -0,0 +1,61 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	ProcessesPath = "/v1/processes/"
+	HealthPath    = "/v1/health"
+)
+
+type HealthResponse struct {
+	DesiredWatchEstablished bool `json:"desired_watch_established"`
+}
+
+func NewHandler(registry Registry, logger lager.Logger) http.Handler {
+	handlerLogger := logger.Session("status")
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(ProcessesPath, func(w http.ResponseWriter, r *http.Request) {
+		processGuid := strings.TrimPrefix(r.URL.Path, ProcessesPath)
+
+		processStatus, ok := registry.Process(processGuid)
+		if processGuid == "" || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, processStatus, handlerLogger)
+	})
+
+	mux.HandleFunc(HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		health := HealthResponse{
+			DesiredWatchEstablished: registry.DesiredWatchEstablished(),
+		}
+
+		statusCode := http.StatusOK
+		if !health.DesiredWatchEstablished {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, statusCode, health, handlerLogger)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}, logger lager.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logger.Error("failed-to-write-response", err)
+	}
+}
@@ -0,0 +1,115 @@
+package status_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/cloudfoundry-incubator/app-manager/status"
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		registry Registry
+		handler  http.Handler
+		recorder *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+		handler = NewHandler(registry, lagertest.NewTestLogger("test"))
+		recorder = httptest.NewRecorder()
+	})
+
+	get := func(path string) {
+		request, err := http.NewRequest("GET", path, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		handler.ServeHTTP(recorder, request)
+	}
+
+	Describe("GET /v1/processes/:guid", func() {
+		Context("when the process is known", func() {
+			var processStatus ProcessStatus
+
+			BeforeEach(func() {
+				processStatus = ProcessStatus{
+					ProcessGuid:      "some-process-guid",
+					DesiredInstances: 3,
+					ActualInstances:  2,
+					LastReconcile: delta_force.Result{
+						IndicesToStart: []int{2},
+					},
+					StartAuctions: []StartAuction{
+						{Index: 2, InstanceGuid: "some-instance-guid"},
+					},
+					LastError: "oh no",
+				}
+
+				registry.RecordProcess(processStatus)
+
+				get("/v1/processes/some-process-guid")
+			})
+
+			It("returns its status as JSON", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusOK))
+				Ω(recorder.HeaderMap.Get("Content-Type")).Should(Equal("application/json"))
+
+				var returned ProcessStatus
+				err := json.Unmarshal(recorder.Body.Bytes(), &returned)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(returned).Should(Equal(processStatus))
+			})
+		})
+
+		Context("when the process is not known", func() {
+			BeforeEach(func() {
+				get("/v1/processes/some-process-guid")
+			})
+
+			It("returns 404", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when no process guid is given", func() {
+			BeforeEach(func() {
+				get("/v1/processes/")
+			})
+
+			It("returns 404", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("GET /v1/health", func() {
+		Context("when the desired LRP watch is established", func() {
+			BeforeEach(func() {
+				registry.SetDesiredWatchEstablished(true)
+				get("/v1/health")
+			})
+
+			It("returns 200", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusOK))
+				Ω(recorder.Body.String()).Should(MatchJSON(`{"desired_watch_established":true}`))
+			})
+		})
+
+		Context("when the desired LRP watch is not established", func() {
+			BeforeEach(func() {
+				get("/v1/health")
+			})
+
+			It("returns 503", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusServiceUnavailable))
+				Ω(recorder.Body.String()).Should(MatchJSON(`{"desired_watch_established":false}`))
+			})
+		})
+	})
+})
@@ -0,0 +1,199 @@
+package status
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-manager/validation"
+	"github.com/cloudfoundry-incubator/delta_force/delta_force"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+// maxRollouts is how many rollouts are kept for each process.
+const maxRollouts = 10
+
+type ProcessStatus struct {
+	ProcessGuid      string `json:"process_guid"`
+	DesiredInstances int    `json:"desired_instances"`
+	ActualInstances  int    `json:"actual_instances"`
+	Updating         bool   `json:"updating"`
+	CanaryFailed     bool   `json:"canary_failed"`
+
+	PendingUnschedulable bool `json:"pending_unschedulable"`
+
+	// Violations are what keeps an invalid desired LRP from being auctioned.
+	Violations []validation.Violation `json:"violations,omitempty"`
+
+	LastReconcile delta_force.Result `json:"last_reconcile"`
+
+	StartAuctions    []StartAuction `json:"start_auctions"`
+	StartsInFlight   []int          `json:"starts_in_flight"`
+	StartsThrottled  []int          `json:"starts_throttled"`
+	StopAuctions     []int          `json:"stop_auctions"`
+	StoppedInstances []string       `json:"stopped_instances"`
+
+	BackingOff []int `json:"backing_off"`
+	GivenUp    []int `json:"given_up"`
+
+	Failures  []Failure `json:"failures"`
+	LastError string    `json:"last_error,omitempty"`
+
+	// ProcessedIndex is the modified index of the last desired change that
+	// was processed for the process.
+	ProcessedIndex uint64 `json:"processed_index,omitempty"`
+
+	// Rollouts are the most recent rolling updates of the process that have
+	// ended, oldest first.
+	Rollouts []Rollout `json:"rollouts,omitempty"`
+}
+
+type StartAuction struct {
+	Index        int    `json:"index"`
+	InstanceGuid string `json:"instance_guid"`
+}
+
+const (
+	StartAction        = "start"
+	StopInstanceAction = "stop-instance"
+	StopAuctionAction  = "stop-auction"
+)
+
+// A Failure is a start or stop that could not be requested. The rest of the
+// process is still reconciled around it.
+type Failure struct {
+	Action       string `json:"action"`
+	Index        int    `json:"index"`
+	InstanceGuid string `json:"instance_guid,omitempty"`
+	Error        string `json:"error"`
+}
+
+const (
+	RolloutCompleted  = "completed"
+	RolloutRolledBack = "rolled-back"
+)
+
+// A Rollout is a rolling update from one spec of a process to another.
+type Rollout struct {
+	Before    models.DesiredLRP `json:"before"`
+	After     models.DesiredLRP `json:"after"`
+	StartedAt time.Time         `json:"started_at"`
+	EndedAt   time.Time         `json:"ended_at"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+type Registry interface {
+	RecordProcess(processStatus ProcessStatus)
+	RemoveProcess(processGuid string)
+	Process(processGuid string) (ProcessStatus, bool)
+
+	RecordProcessedIndex(processGuid string, index uint64)
+	ProcessedIndex(processGuid string) (uint64, bool)
+	ForgetProcessedIndex(processGuid string)
+
+	RecordRollout(processGuid string, rollout Rollout)
+
+	SetDesiredWatchEstablished(established bool)
+	DesiredWatchEstablished() bool
+}
+
+type registry struct {
+	processes               map[string]ProcessStatus
+	processedIndices        map[string]uint64
+	rollouts                map[string][]Rollout
+	desiredWatchEstablished bool
+	lock                    sync.RWMutex
+}
+
+func NewRegistry() Registry {
+	return &registry{
+		processes:        map[string]ProcessStatus{},
+		processedIndices: map[string]uint64{},
+		rollouts:         map[string][]Rollout{},
+	}
+}
+
+// RecordProcess replaces the status of the process, except that the last
+// error is kept until a newer one is recorded.
+func (r *registry) RecordProcess(processStatus ProcessStatus) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if processStatus.LastError == "" {
+		processStatus.LastError = r.processes[processStatus.ProcessGuid].LastError
+	}
+
+	r.processes[processStatus.ProcessGuid] = processStatus
+}
+
+func (r *registry) RemoveProcess(processGuid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.processes, processGuid)
+	delete(r.rollouts, processGuid)
+}
+
+func (r *registry) Process(processGuid string) (ProcessStatus, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	processStatus, ok := r.processes[processGuid]
+	processStatus.ProcessedIndex = r.processedIndices[processGuid]
+	processStatus.Rollouts = r.rollouts[processGuid]
+
+	return processStatus, ok
+}
+
+// Processed indices outlive the processes they belong to, so that changes
+// from before a process was removed are still known to be old. They are
+// only forgotten when told to, once nothing of the process is left.
+func (r *registry) RecordProcessedIndex(processGuid string, index uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.processedIndices[processGuid] = index
+}
+
+func (r *registry) ProcessedIndex(processGuid string) (uint64, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	index, ok := r.processedIndices[processGuid]
+	return index, ok
+}
+
+func (r *registry) ForgetProcessedIndex(processGuid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.processedIndices, processGuid)
+}
+
+// RecordRollout adds to the history of the process, dropping its oldest
+// rollout once maxRollouts are kept.
+func (r *registry) RecordRollout(processGuid string, rollout Rollout) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rollouts := append(r.rollouts[processGuid], rollout)
+	if len(rollouts) > maxRollouts {
+		rollouts = rollouts[len(rollouts)-maxRollouts:]
+	}
+
+	r.rollouts[processGuid] = rollouts
+}
+
+func (r *registry) SetDesiredWatchEstablished(established bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.desiredWatchEstablished = established
+}
+
+func (r *registry) DesiredWatchEstablished() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.desiredWatchEstablished
+}
@@ -0,0 +1,124 @@
+package status_test
+
+import (
+	"fmt"
+
+	. "github.com/cloudfoundry-incubator/app-manager/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var registry Registry
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+	})
+
+	It("knows nothing about processes it has not been told about", func() {
+		_, ok := registry.Process("some-process-guid")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("returns the latest status recorded for a process", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid", DesiredInstances: 1})
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid", DesiredInstances: 2})
+
+		processStatus, ok := registry.Process("some-process-guid")
+		Ω(ok).Should(BeTrue())
+		Ω(processStatus.DesiredInstances).Should(Equal(2))
+	})
+
+	It("keeps the last error until a newer one is recorded", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid", LastError: "oh no"})
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+
+		processStatus, _ := registry.Process("some-process-guid")
+		Ω(processStatus.LastError).Should(Equal("oh no"))
+
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid", LastError: "oh no, again"})
+
+		processStatus, _ = registry.Process("some-process-guid")
+		Ω(processStatus.LastError).Should(Equal("oh no, again"))
+	})
+
+	It("forgets removed processes", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+		registry.RemoveProcess("some-process-guid")
+
+		_, ok := registry.Process("some-process-guid")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("reports the last processed index along with the status of a process", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+		registry.RecordProcessedIndex("some-process-guid", 42)
+
+		processStatus, _ := registry.Process("some-process-guid")
+		Ω(processStatus.ProcessedIndex).Should(Equal(uint64(42)))
+	})
+
+	It("keeps the last processed index of removed processes", func() {
+		_, ok := registry.ProcessedIndex("some-process-guid")
+		Ω(ok).Should(BeFalse())
+
+		registry.RecordProcessedIndex("some-process-guid", 42)
+		registry.RemoveProcess("some-process-guid")
+
+		index, ok := registry.ProcessedIndex("some-process-guid")
+		Ω(ok).Should(BeTrue())
+		Ω(index).Should(Equal(uint64(42)))
+	})
+
+	It("forgets the last processed index of a process when told to", func() {
+		registry.RecordProcessedIndex("some-process-guid", 42)
+		registry.ForgetProcessedIndex("some-process-guid")
+
+		_, ok := registry.ProcessedIndex("some-process-guid")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("reports the rollouts of a process along with its status", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+		registry.RecordRollout("some-process-guid", Rollout{Outcome: RolloutCompleted})
+		registry.RecordRollout("some-process-guid", Rollout{Outcome: RolloutRolledBack, Reason: "oh no"})
+
+		processStatus, _ := registry.Process("some-process-guid")
+		Ω(processStatus.Rollouts).Should(Equal([]Rollout{
+			{Outcome: RolloutCompleted},
+			{Outcome: RolloutRolledBack, Reason: "oh no"},
+		}))
+	})
+
+	It("only keeps the most recent rollouts of a process", func() {
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+		for i := 0; i < 12; i++ {
+			registry.RecordRollout("some-process-guid", Rollout{Reason: fmt.Sprintf("rollout-%d", i)})
+		}
+
+		processStatus, _ := registry.Process("some-process-guid")
+		Ω(processStatus.Rollouts).Should(HaveLen(10))
+		Ω(processStatus.Rollouts[0].Reason).Should(Equal("rollout-2"))
+		Ω(processStatus.Rollouts[9].Reason).Should(Equal("rollout-11"))
+	})
+
+	It("forgets the rollouts of removed processes", func() {
+		registry.RecordRollout("some-process-guid", Rollout{Outcome: RolloutCompleted})
+		registry.RemoveProcess("some-process-guid")
+		registry.RecordProcess(ProcessStatus{ProcessGuid: "some-process-guid"})
+
+		processStatus, _ := registry.Process("some-process-guid")
+		Ω(processStatus.Rollouts).Should(BeEmpty())
+	})
+
+	It("tracks whether the desired LRP watch is established", func() {
+		Ω(registry.DesiredWatchEstablished()).Should(BeFalse())
+
+		registry.SetDesiredWatchEstablished(true)
+		Ω(registry.DesiredWatchEstablished()).Should(BeTrue())
+
+		registry.SetDesiredWatchEstablished(false)
+		Ω(registry.DesiredWatchEstablished()).Should(BeFalse())
+	})
+})
@@ -0,0 +1,173 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+// A Violation is one way in which a desired LRP could not run. Its field is
+// the path to the offending value, as it is named in the JSON of the LRP.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s", v.Field, v.Message)
+}
+
+// A Validator finds what is wrong with a desired LRP before any of its
+// instances are auctioned, rather than leaving it to the executors.
+type Validator interface {
+	Validate(lrp models.DesiredLRP) []Violation
+}
+
+type rule func(lrp models.DesiredLRP) []Violation
+
+type validator struct {
+	rules []rule
+}
+
+// New returns a validator that checks the identity, resources, ports and
+// actions of an LRP, reporting every violation it finds.
+func New() Validator {
+	return &validator{
+		rules: []rule{
+			validateIdentity,
+			validateResources,
+			validatePorts,
+			validateActions,
+		},
+	}
+}
+
+func (v *validator) Validate(lrp models.DesiredLRP) []Violation {
+	violations := []Violation{}
+
+	for _, rule := range v.rules {
+		violations = append(violations, rule(lrp)...)
+	}
+
+	return violations
+}
+
+func validateIdentity(lrp models.DesiredLRP) []Violation {
+	violations := []Violation{}
+
+	if lrp.ProcessGuid == "" {
+		violations = append(violations, Violation{"process_guid", "is required"})
+	}
+
+	if lrp.Stack == "" {
+		violations = append(violations, Violation{"stack", "is required"})
+	}
+
+	return violations
+}
+
+func validateResources(lrp models.DesiredLRP) []Violation {
+	violations := []Violation{}
+
+	if lrp.Instances < 0 {
+		violations = append(violations, Violation{"instances", "must not be negative"})
+	}
+
+	if lrp.MemoryMB <= 0 {
+		violations = append(violations, Violation{"memory_mb", "must be positive"})
+	}
+
+	if lrp.DiskMB < 0 {
+		violations = append(violations, Violation{"disk_mb", "must not be negative"})
+	}
+
+	return violations
+}
+
+func validatePorts(lrp models.DesiredLRP) []Violation {
+	violations := []Violation{}
+
+	seen := map[uint32]int{}
+	for i, port := range lrp.Ports {
+		field := fmt.Sprintf("ports[%d].container_port", i)
+
+		if port.ContainerPort == 0 {
+			violations = append(violations, Violation{field, "is required"})
+			continue
+		}
+
+		if first, found := seen[port.ContainerPort]; found {
+			violations = append(violations, Violation{field, fmt.Sprintf("duplicates ports[%d]", first)})
+			continue
+		}
+
+		seen[port.ContainerPort] = i
+	}
+
+	return violations
+}
+
+func validateActions(lrp models.DesiredLRP) []Violation {
+	return validateActionList("actions", lrp.Actions)
+}
+
+func validateActionList(field string, actions []models.ExecutorAction) []Violation {
+	if len(actions) == 0 {
+		return []Violation{{field, "must not be empty"}}
+	}
+
+	violations := []Violation{}
+	for i, action := range actions {
+		violations = append(violations, validateAction(fmt.Sprintf("%s[%d]", field, i), action)...)
+	}
+
+	return violations
+}
+
+// validateAction walks down the actions nested in action, so that a
+// violation deep inside a parallel or monitor action is found as well.
+func validateAction(field string, action models.ExecutorAction) []Violation {
+	violations := []Violation{}
+
+	required := func(name string, value string) {
+		if value == "" {
+			violations = append(violations, Violation{field + "." + name, "is required"})
+		}
+	}
+
+	switch a := action.Action.(type) {
+	case models.DownloadAction:
+		required("download.from", a.From)
+		required("download.to", a.To)
+
+	case models.UploadAction:
+		required("upload.from", a.From)
+		required("upload.to", a.To)
+
+	case models.RunAction:
+		required("run.path", a.Path)
+
+	case models.FetchResultAction:
+		required("fetch_result.file", a.File)
+
+	case models.EmitProgressAction:
+		violations = append(violations, validateAction(field+".emit_progress.action", a.Action)...)
+
+	case models.TryAction:
+		violations = append(violations, validateAction(field+".try.action", a.Action)...)
+
+	case models.MonitorAction:
+		violations = append(violations, validateAction(field+".monitor.action", a.Action)...)
+
+	case models.ParallelAction:
+		violations = append(violations, validateActionList(field+".parallel.actions", a.Actions)...)
+
+	case nil:
+		violations = append(violations, Violation{field, "is required"})
+
+	default:
+		violations = append(violations, Violation{field, fmt.Sprintf("is of unknown type %T", a)})
+	}
+
+	return violations
+}
@@ -0,0 +1,162 @@
+package validation_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-manager/validation"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		validator  Validator
+		desiredLRP models.DesiredLRP
+	)
+
+	BeforeEach(func() {
+		validator = New()
+
+		desiredLRP = models.DesiredLRP{
+			ProcessGuid: "some-process-guid",
+			Stack:       "some-stack",
+
+			Instances: 2,
+			MemoryMB:  128,
+			DiskMB:    512,
+
+			Ports: []models.PortMapping{
+				{ContainerPort: 8080},
+				{ContainerPort: 9090},
+			},
+
+			Actions: []models.ExecutorAction{
+				{
+					Action: models.DownloadAction{
+						From: "http://some.file.server/droplet",
+						To:   "/app",
+					},
+				},
+				models.Parallel(
+					models.ExecutorAction{
+						Action: models.RunAction{Path: "some-run-action-path"},
+					},
+					models.EmitProgressFor(
+						models.ExecutorAction{
+							Action: models.RunAction{Path: "some-other-run-action-path"},
+						},
+						"starting", "started", "failed",
+					),
+				),
+			},
+		}
+	})
+
+	It("finds nothing wrong with a valid LRP", func() {
+		Ω(validator.Validate(desiredLRP)).Should(BeEmpty())
+	})
+
+	It("allows no instances", func() {
+		desiredLRP.Instances = 0
+		Ω(validator.Validate(desiredLRP)).Should(BeEmpty())
+	})
+
+	It("requires a process guid and a stack", func() {
+		desiredLRP.ProcessGuid = ""
+		desiredLRP.Stack = ""
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "process_guid", Message: "is required"},
+			{Field: "stack", Message: "is required"},
+		}))
+	})
+
+	It("rejects impossible resources", func() {
+		desiredLRP.Instances = -1
+		desiredLRP.MemoryMB = 0
+		desiredLRP.DiskMB = -1
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "instances", Message: "must not be negative"},
+			{Field: "memory_mb", Message: "must be positive"},
+			{Field: "disk_mb", Message: "must not be negative"},
+		}))
+	})
+
+	It("rejects missing and duplicate container ports", func() {
+		desiredLRP.Ports = []models.PortMapping{
+			{ContainerPort: 8080},
+			{ContainerPort: 0},
+			{ContainerPort: 8080, HostPort: 61000},
+		}
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "ports[1].container_port", Message: "is required"},
+			{Field: "ports[2].container_port", Message: "duplicates ports[0]"},
+		}))
+	})
+
+	It("requires actions", func() {
+		desiredLRP.Actions = nil
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "actions", Message: "must not be empty"},
+		}))
+	})
+
+	It("finds violations in nested actions", func() {
+		desiredLRP.Actions = []models.ExecutorAction{
+			models.Parallel(
+				models.ExecutorAction{Action: models.RunAction{}},
+				models.Try(models.ExecutorAction{Action: models.FetchResultAction{}}),
+				models.Parallel(),
+				models.ExecutorAction{
+					Action: models.MonitorAction{
+						Action: models.ExecutorAction{Action: models.UploadAction{From: "/tmp/result"}},
+					},
+				},
+			),
+		}
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "actions[0].parallel.actions[0].run.path", Message: "is required"},
+			{Field: "actions[0].parallel.actions[1].try.action.fetch_result.file", Message: "is required"},
+			{Field: "actions[0].parallel.actions[2].parallel.actions", Message: "must not be empty"},
+			{Field: "actions[0].parallel.actions[3].monitor.action.upload.to", Message: "is required"},
+		}))
+	})
+
+	It("rejects actions it does not know", func() {
+		desiredLRP.Actions = []models.ExecutorAction{
+			models.Parallel(
+				models.ExecutorAction{Action: &models.RunAction{Path: "some-run-action-path"}},
+				models.ExecutorAction{},
+			),
+		}
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "actions[0].parallel.actions[0]", Message: "is of unknown type *models.RunAction"},
+			{Field: "actions[0].parallel.actions[1]", Message: "is required"},
+		}))
+	})
+
+	It("reports every violation it finds", func() {
+		desiredLRP.MemoryMB = 0
+		desiredLRP.Actions = []models.ExecutorAction{
+			{Action: models.DownloadAction{}},
+		}
+
+		Ω(validator.Validate(desiredLRP)).Should(Equal([]Violation{
+			{Field: "memory_mb", Message: "must be positive"},
+			{Field: "actions[0].download.from", Message: "is required"},
+			{Field: "actions[0].download.to", Message: "is required"},
+		}))
+	})
+
+	Describe("a violation", func() {
+		It("reads as the field followed by what is wrong with it", func() {
+			violation := Violation{Field: "memory_mb", Message: "must be positive"}
+			Ω(violation.String()).Should(Equal("memory_mb must be positive"))
+		})
+	})
+})
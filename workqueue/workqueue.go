@@ -0,0 +1,80 @@
+package workqueue
+
+import "sync"
+
+// A WorkQueue runs work on a bounded number of goroutines, never running
+// two pieces of work for the same key at once. Work enqueued for a key that
+// has not started yet replaces whatever was waiting for that key.
+type WorkQueue struct {
+	workers int
+
+	lock    sync.Mutex
+	pending map[string]func()
+	running map[string]bool
+	ready   []string
+	active  int
+
+	wg sync.WaitGroup
+}
+
+func New(workers int) *WorkQueue {
+	return &WorkQueue{
+		workers: workers,
+		pending: map[string]func(){},
+		running: map[string]bool{},
+	}
+}
+
+func (q *WorkQueue) Enqueue(key string, work func()) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, alreadyPending := q.pending[key]
+	if !alreadyPending && !q.running[key] {
+		q.ready = append(q.ready, key)
+	}
+
+	q.pending[key] = work
+
+	if len(q.ready) > 0 && q.active < q.workers {
+		q.active++
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Wait blocks until all enqueued work has run. It must not be called
+// concurrently with Enqueue.
+func (q *WorkQueue) Wait() {
+	q.wg.Wait()
+}
+
+func (q *WorkQueue) work() {
+	defer q.wg.Done()
+
+	for {
+		q.lock.Lock()
+		if len(q.ready) == 0 {
+			q.active--
+			q.lock.Unlock()
+			return
+		}
+
+		key := q.ready[0]
+		q.ready = q.ready[1:]
+
+		work := q.pending[key]
+		delete(q.pending, key)
+		q.running[key] = true
+		q.lock.Unlock()
+
+		work()
+
+		q.lock.Lock()
+		delete(q.running, key)
+		if _, hasMore := q.pending[key]; hasMore {
+			q.ready = append(q.ready, key)
+		}
+		q.lock.Unlock()
+	}
+}
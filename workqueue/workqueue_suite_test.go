@@ -0,0 +1,13 @@
+package workqueue_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWorkQueue(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WorkQueue Suite")
+}
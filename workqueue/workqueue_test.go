@@ -0,0 +1,144 @@
+package workqueue_test
+
+import (
+	"sync"
+
+	. "github.com/cloudfoundry-incubator/app-manager/workqueue"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WorkQueue", func() {
+	var queue *WorkQueue
+
+	BeforeEach(func() {
+		queue = New(2)
+	})
+
+	AfterEach(func() {
+		queue.Wait()
+	})
+
+	It("runs enqueued work", func() {
+		ran := make(chan string, 2)
+
+		queue.Enqueue("a", func() { ran <- "a" })
+		queue.Enqueue("b", func() { ran <- "b" })
+
+		Eventually(ran).Should(Receive())
+		Eventually(ran).Should(Receive())
+	})
+
+	Describe("work for the same key", func() {
+		var release chan struct{}
+		var ran chan string
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			ran = make(chan string, 3)
+
+			queue.Enqueue("a", func() {
+				ran <- "first"
+				<-release
+			})
+
+			Eventually(ran).Should(Receive(Equal("first")))
+		})
+
+		It("does not run until the running work is done", func() {
+			queue.Enqueue("a", func() { ran <- "second" })
+
+			Consistently(ran).ShouldNot(Receive())
+
+			close(release)
+
+			Eventually(ran).Should(Receive(Equal("second")))
+		})
+
+		It("only runs the latest of the work that is waiting", func() {
+			queue.Enqueue("a", func() { ran <- "second" })
+			queue.Enqueue("a", func() { ran <- "third" })
+
+			close(release)
+
+			Eventually(ran).Should(Receive(Equal("third")))
+			Consistently(ran).ShouldNot(Receive())
+		})
+
+		It("does not hold up work for other keys", func() {
+			queue.Enqueue("b", func() { ran <- "other" })
+
+			Eventually(ran).Should(Receive(Equal("other")))
+
+			close(release)
+		})
+	})
+
+	It("runs no more work at once than it has workers", func() {
+		release := make(chan struct{})
+
+		lock := new(sync.Mutex)
+		running := 0
+		maxRunning := 0
+
+		for _, key := range []string{"a", "b", "c", "d"} {
+			queue.Enqueue(key, func() {
+				lock.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				lock.Unlock()
+
+				<-release
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+			})
+		}
+
+		Eventually(func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return running
+		}).Should(Equal(2))
+
+		close(release)
+		queue.Wait()
+
+		Ω(maxRunning).Should(Equal(2))
+	})
+
+	Describe("Wait", func() {
+		It("waits for running and waiting work", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			ran := make(chan string, 2)
+
+			queue.Enqueue("a", func() {
+				close(started)
+				<-release
+				ran <- "first"
+			})
+
+			Eventually(started).Should(BeClosed())
+
+			queue.Enqueue("a", func() { ran <- "second" })
+
+			waited := make(chan struct{})
+			go func() {
+				queue.Wait()
+				close(waited)
+			}()
+
+			Consistently(waited).ShouldNot(BeClosed())
+
+			close(release)
+
+			Eventually(waited).Should(BeClosed())
+			Ω(ran).Should(HaveLen(2))
+		})
+	})
+})